@@ -0,0 +1,218 @@
+package restgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// requestBody 请求体，长度已知时设置 Content-Length，能够重新生成时支持重定向以及重试时重新发送
+type requestBody struct {
+	reader io.Reader
+	// closer 只关闭内部创建的 reader，调用方传入的 reader 由调用方自行关闭
+	closer io.Closer
+	// length 请求体长度，-1 表示未知
+	length  int64
+	getBody func() (io.ReadCloser, error)
+	// data 请求体在内存中时的内容，用于生成 curl
+	data []byte
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *requestBody) Close() error {
+	if b.closer != nil {
+		return b.closer.Close()
+	}
+	return nil
+}
+
+// seekerOffset 获取 seeker 当前的读取位置
+func seekerOffset(seeker io.Seeker) (int64, bool) {
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	return offset, err == nil
+}
+
+func newBytesBody(data []byte) *requestBody {
+	return &requestBody{
+		reader: bytes.NewReader(data),
+		length: int64(len(data)),
+		data:   data,
+		getBody: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+}
+
+// newReaderBody 使用调用方提供的 reader 作为请求体，reader 实现了 io.Seeker 时支持重新发送
+func newReaderBody(reader io.Reader) *requestBody {
+	return newReaderSource(reader).newBody()
+}
+
+// readerSource 调用方提供的 reader，设置时记录读取位置以及剩余长度，
+// 每次生成请求体时回到该位置，重试时重新生成的请求体与第一次一致
+type readerSource struct {
+	reader io.Reader
+	seeker io.Seeker
+	start  int64
+	size   int64
+	used   bool
+}
+
+func newReaderSource(reader io.Reader) *readerSource {
+	source := &readerSource{reader: reader, size: readerSize(reader)}
+	if seeker, ok := reader.(io.Seeker); ok {
+		if start, ok := seekerOffset(seeker); ok {
+			source.seeker, source.start = seeker, start
+		}
+	}
+	return source
+}
+
+func (s *readerSource) rewindable() bool {
+	return s.seeker != nil
+}
+
+// rewind 回到设置时的读取位置
+func (s *readerSource) rewind() error {
+	_, err := s.seeker.Seek(s.start, io.SeekStart)
+	return err
+}
+
+// reset 生成请求体前调用，回到设置时的读取位置，无法回退的 reader 只能发送一次
+func (s *readerSource) reset() error {
+	if s.rewindable() {
+		return s.rewind()
+	}
+	if s.used {
+		return errors.New("request body can not be resent")
+	}
+	s.used = true
+	return nil
+}
+
+// newBody 从当前位置读取的请求体
+func (s *readerSource) newBody() *requestBody {
+	body := &requestBody{reader: s.reader, length: s.size}
+	if s.rewindable() {
+		body.getBody = func() (io.ReadCloser, error) {
+			if err := s.rewind(); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(s.reader), nil
+		}
+	}
+	return body
+}
+
+// readerSize 获取 reader 剩余可读取的长度，无法获取时返回 -1
+func readerSize(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case *bytes.Buffer:
+		return int64(r.Len())
+	case *bytes.Reader:
+		return int64(r.Len())
+	case *strings.Reader:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// newPipeBody 通过 io.Pipe 边生成边发送请求体，避免将整个请求体读入内存
+// 生成协程在第一次读取时才启动，请求没有发出时不会泄漏协程
+func newPipeBody(length int64, write func(w io.Writer) error, rewindable bool) *requestBody {
+	reader := newLazyPipeReader(write)
+	body := &requestBody{
+		reader: reader,
+		closer: reader,
+		length: length,
+	}
+	if rewindable {
+		body.getBody = func() (io.ReadCloser, error) {
+			return newLazyPipeReader(write), nil
+		}
+	}
+	return body
+}
+
+type lazyPipeReader struct {
+	once  sync.Once
+	write func(w io.Writer) error
+	pr    *io.PipeReader
+}
+
+func newLazyPipeReader(write func(w io.Writer) error) *lazyPipeReader {
+	return &lazyPipeReader{write: write}
+}
+
+func (r *lazyPipeReader) start() {
+	r.once.Do(func() {
+		pr, pw := io.Pipe()
+		r.pr = pr
+		go func() {
+			_ = pw.CloseWithError(r.write(pw))
+		}()
+	})
+}
+
+func (r *lazyPipeReader) Read(p []byte) (int, error) {
+	r.start()
+	return r.pr.Read(p)
+}
+
+// Close 还没有开始读取时直接关闭，不再启动生成协程
+func (r *lazyPipeReader) Close() error {
+	r.once.Do(func() {
+		r.pr, _ = io.Pipe()
+	})
+	return r.pr.Close()
+}
+
+// newHTTPRequest 创建请求，请求体为 requestBody 时设置 Content-Length 以及 GetBody
+func newHTTPRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if rb, ok := body.(*requestBody); ok {
+		switch {
+		case rb.length == 0:
+			req.Body = http.NoBody
+			req.ContentLength = 0
+			req.GetBody = func() (io.ReadCloser, error) {
+				return http.NoBody, nil
+			}
+		default:
+			req.ContentLength = rb.length
+			req.GetBody = rb.getBody
+		}
+	}
+	return req, nil
+}
+
+// countWriter 只统计写入的字节数，用于计算请求体长度
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
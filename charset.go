@@ -0,0 +1,160 @@
+package restgo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// CharsetDecoder 将指定字符集的内容转换为 UTF-8
+type CharsetDecoder func(r io.Reader) io.Reader
+
+var (
+	charsetLock     sync.RWMutex
+	charsetDecoders = map[string]CharsetDecoder{}
+)
+
+// RegisterCharset 注册字符集的解码器，已存在时覆盖，未注册的字符集按 WHATWG 编码标准查找，
+// 支持 GBK、GB18030、Big5、Shift_JIS、ISO-8859-1 等常见字符集
+func RegisterCharset(charset string, decoder CharsetDecoder) {
+	charsetLock.Lock()
+	defer charsetLock.Unlock()
+	charsetDecoders[strings.ToLower(charset)] = decoder
+}
+
+// lookupCharset 查找字符集的解码器，UTF-8 以及无法识别的字符集返回 nil
+func lookupCharset(charset string) (CharsetDecoder, error) {
+	charset = strings.ToLower(strings.Trim(strings.TrimSpace(charset), `"'`))
+	if charset == "" || charset == "utf-8" || charset == "utf8" {
+		return nil, nil
+	}
+	charsetLock.RLock()
+	decoder, ok := charsetDecoders[charset]
+	charsetLock.RUnlock()
+	if ok {
+		return decoder, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("charset:[%s] not support", charset)
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return nil, nil
+	}
+	return func(r io.Reader) io.Reader {
+		return enc.NewDecoder().Reader(r)
+	}, nil
+}
+
+// RawCharset 不转换响应体的字符集，BodyStr、BodyUnmarshal 直接使用原始响应体，适用于二进制数据
+func (builder *Builder) RawCharset() *Builder {
+	builder.rawCharset = true
+	return builder
+}
+
+// SniffCharset Content-Type 没有指定字符集时，从 HTML 的 meta 标签以及 XML 声明中识别字符集
+func (builder *Builder) SniffCharset() *Builder {
+	builder.sniffCharset = true
+	return builder
+}
+
+// charsetOptions 响应体字符集的处理方式
+type charsetOptions struct {
+	raw   bool
+	sniff bool
+}
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+
+	metaCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_\-:.]+)`)
+	xmlDeclPattern     = regexp.MustCompile(`^\s*<\?xml[^>]*?encoding\s*=\s*["']([a-zA-Z0-9_\-:.]+)["'][^>]*\?>`)
+)
+
+// sniffLimit 识别 meta 标签时最多检查的长度
+const sniffLimit = 1024
+
+// decodeCharset 将响应体转换为 UTF-8，字符集优先级：BOM > Content-Type > meta 标签/XML 声明，
+// 识别不到时按 UTF-8 处理。转换后的 XML 会将声明中的编码改为 UTF-8，避免 encoding/xml 再次转换
+func decodeCharset(contentType string, body []byte, opts charsetOptions) ([]byte, error) {
+	if opts.raw || len(body) == 0 {
+		return body, nil
+	}
+	switch {
+	case bytes.HasPrefix(body, utf8BOM):
+		return body[len(utf8BOM):], nil
+	case bytes.HasPrefix(body, utf16LEBOM):
+		return transcode(body, unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Reader)
+	case bytes.HasPrefix(body, utf16BEBOM):
+		return transcode(body, unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Reader)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	charset := params["charset"]
+	if charset == "" && opts.sniff {
+		charset = sniffCharset(mediaType, body)
+	}
+	// 无法识别的字符集按原始内容处理
+	decoder, err := lookupCharset(charset)
+	if err != nil || decoder == nil {
+		return body, nil
+	}
+	decoded, err := transcode(body, decoder)
+	if err != nil {
+		return nil, err
+	}
+	return rewriteXMLEncoding(decoded), nil
+}
+
+func transcode(body []byte, decoder CharsetDecoder) ([]byte, error) {
+	return io.ReadAll(decoder(bytes.NewReader(body)))
+}
+
+// sniffCharset 从 XML 声明以及 HTML 的 meta 标签中识别字符集
+func sniffCharset(mediaType string, body []byte) string {
+	if len(body) > sniffLimit {
+		body = body[:sniffLimit]
+	}
+	if match := xmlDeclPattern.FindSubmatch(body); match != nil {
+		return string(match[1])
+	}
+	if strings.Contains(mediaType, "html") || mediaType == "" {
+		if match := metaCharsetPattern.FindSubmatch(body); match != nil {
+			return string(match[1])
+		}
+	}
+	return ""
+}
+
+// rewriteXMLEncoding 将 XML 声明中的编码改为 UTF-8
+func rewriteXMLEncoding(body []byte) []byte {
+	loc := xmlDeclPattern.FindSubmatchIndex(body)
+	if loc == nil {
+		return body
+	}
+	rewritten := make([]byte, 0, len(body))
+	rewritten = append(rewritten, body[:loc[2]]...)
+	rewritten = append(rewritten, "UTF-8"...)
+	return append(rewritten, body[loc[3]:]...)
+}
+
+// xmlCharsetReader 用于 encoding/xml 解析非 UTF-8 编码声明的内容
+func xmlCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	decoder, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	if decoder == nil {
+		return input, nil
+	}
+	return decoder(input), nil
+}
@@ -0,0 +1,140 @@
+package restgo
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/avast/retry-go"
+)
+
+// Client 可复用的客户端，持有公共的 BaseUrl、请求头、查询参数、中间件以及重试策略，
+// 通过 R 方法派生出预先填充好默认配置的 Builder，Builder 上的设置会合并覆盖 Client 的默认值
+type Client struct {
+	baseURL           string
+	headers           map[string]string
+	queryVal          url.Values
+	middlewares       []Middleware
+	streamMiddlewares []StreamMiddleware
+	retryOptions      []retry.Option
+	timeout           time.Duration
+	expectSuccess     bool
+	codec             Codec
+	maxBodySize       int64
+	httpClient        *http.Client
+	restGo            RestGo
+	streamRestGo      StreamRestGo
+}
+
+// NewClient 创建客户端，客户端持有独立的 http.Client，默认与全局客户端共享连接池
+func NewClient() *Client {
+	c := &Client{
+		headers:  make(map[string]string),
+		queryVal: make(url.Values),
+		timeout:  DefaultTimeout,
+	}
+	return c.HttpClient(&http.Client{
+		Transport: initClient().Transport,
+	})
+}
+
+// BaseUrl 设置默认的基础URL
+func (c *Client) BaseUrl(baseURL string) *Client {
+	c.baseURL = baseURL
+	return c
+}
+
+// Header 设置单个默认请求头
+func (c *Client) Header(key, val string) *Client {
+	c.headers[key] = val
+	return c
+}
+
+// Headers 批量设置默认请求头，与已有的默认请求头合并
+func (c *Client) Headers(headers map[string]string) *Client {
+	for k, v := range headers {
+		c.headers[k] = v
+	}
+	return c
+}
+
+// Query 批量设置默认查询参数，与已有的默认查询参数合并
+func (c *Client) Query(queryVal map[string]string) *Client {
+	for k, v := range queryVal {
+		c.queryVal.Set(k, v)
+	}
+	return c
+}
+
+// Use 注册请求中间件，位于 Builder 注册的中间件外层
+func (c *Client) Use(middlewares ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, middlewares...)
+	return c
+}
+
+// UseStream 注册流式请求中间件，位于 Builder 注册的中间件外层
+func (c *Client) UseStream(middlewares ...StreamMiddleware) *Client {
+	c.streamMiddlewares = append(c.streamMiddlewares, middlewares...)
+	return c
+}
+
+// Retry 设置默认重试策略，SendWithRetry 传入的选项会追加在其后，同类选项以后者为准
+func (c *Client) Retry(ops ...retry.Option) *Client {
+	c.retryOptions = append(c.retryOptions, ops...)
+	return c
+}
+
+// Timeout 设置普通请求的默认超时时间，0表示不设置，流式请求不受影响
+func (c *Client) Timeout(d time.Duration) *Client {
+	c.timeout = d
+	return c
+}
+
+// ExpectSuccess 该客户端派生的所有请求在响应状态码不是2xx时都返回 *HTTPError
+func (c *Client) ExpectSuccess() *Client {
+	c.expectSuccess = true
+	return c
+}
+
+// Codec 设置默认的编解码器，用于序列化请求载荷，以及在响应 Content-Type 无法识别时反序列化响应体
+func (c *Client) Codec(codec Codec) *Client {
+	c.codec = codec
+	return c
+}
+
+// MaxBodySize 读取响应体到内存时允许的最大长度，超过时返回 ErrBodyTooLarge，默认不限制
+func (c *Client) MaxBodySize(n int64) *Client {
+	c.maxBodySize = n
+	return c
+}
+
+// Transport 为客户端创建独立的 http.Transport，不再与全局客户端共享连接池
+func (c *Client) Transport(opts ...TransportOption) *Client {
+	c.httpClient.Transport = NewTransport(opts...)
+	return c
+}
+
+// HttpClient 使用自定义的 http.Client 发送请求
+func (c *Client) HttpClient(cli *http.Client) *Client {
+	c.httpClient = cli
+	c.restGo = NewDefaultRestGo(cli, nil)
+	c.streamRestGo = NewDefaultStreamRestGo(cli, nil)
+	return c
+}
+
+// GetHttpClient 获取客户端使用的 http.Client
+func (c *Client) GetHttpClient() *http.Client {
+	return c.httpClient
+}
+
+// R 派生一个新的 Builder，每个请求都应该使用独立的 Builder
+func (c *Client) R() *Builder {
+	builder := NewRestGoBuilder()
+	builder.client = c
+	builder.restGo = c.restGo
+	builder.streamRestGo = c.streamRestGo
+	if c.codec != nil {
+		builder.Codec(c.codec)
+	}
+	return builder
+}
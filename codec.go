@@ -0,0 +1,121 @@
+package restgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// Codec 请求体/响应体的编解码器，可以通过 RegisterCodec 注册第三方实现（如 msgpack、protobuf）
+type Codec interface {
+	// ContentType 编码后请求体的 Content-Type
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return string(ApplicationJson)
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return string(ApplicationXml)
+}
+
+// Marshal 序列化结果带有 XML 声明头，部分 SOAP 服务要求必须携带
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// Unmarshal 支持 XML 声明中指定 GBK 等非 UTF-8 编码
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = xmlCharsetReader
+	return decoder.Decode(v)
+}
+
+var (
+	// JSONCodec 基于 encoding/json 的编解码器
+	JSONCodec Codec = jsonCodec{}
+	// XMLCodec 基于 encoding/xml 的编解码器
+	XMLCodec Codec = xmlCodec{}
+)
+
+var (
+	codecLock sync.RWMutex
+	codecs    = map[string]Codec{
+		"application/json": JSONCodec,
+		"text/json":        JSONCodec,
+		"application/xml":  XMLCodec,
+		"text/xml":         XMLCodec,
+	}
+)
+
+// RegisterCodec 注册编解码器，codec.ContentType() 以及 mediaTypes 对应的响应都会使用该编解码器反序列化
+func RegisterCodec(codec Codec, mediaTypes ...string) {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	for _, mediaType := range append([]string{codec.ContentType()}, mediaTypes...) {
+		if parsed, _, err := mime.ParseMediaType(mediaType); err == nil {
+			codecs[parsed] = codec
+		}
+	}
+}
+
+// lookupCodec 根据 Content-Type 查找编解码器，支持 application/problem+json 这类结构化后缀
+func lookupCodec(contentType string) Codec {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	if codec, ok := codecs[mediaType]; ok {
+		return codec
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return codecs["application/json"]
+	case strings.HasSuffix(mediaType, "+xml"):
+		return codecs["application/xml"]
+	}
+	return nil
+}
+
+// sameMediaType 忽略参数以及大小写比较两个 Content-Type 的媒体类型
+func sameMediaType(a, b string) bool {
+	mediaTypeA, _, errA := mime.ParseMediaType(a)
+	mediaTypeB, _, errB := mime.ParseMediaType(b)
+	return errA == nil && errB == nil && mediaTypeA == mediaTypeB
+}
+
+// unmarshalBody 根据响应的 Content-Type 选择编解码器，无法识别时使用 fallback，fallback 为空时按 JSON 处理
+func unmarshalBody(contentType string, body []byte, v interface{}, fallback Codec) error {
+	codec := lookupCodec(contentType)
+	if codec == nil {
+		codec = fallback
+	}
+	if codec == nil {
+		codec = JSONCodec
+	}
+	return codec.Unmarshal(body, v)
+}
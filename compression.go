@@ -0,0 +1,262 @@
+package restgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Decompressor 根据 Content-Encoding 解压响应体
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// Compressor 根据 Content-Encoding 压缩请求体
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+var (
+	// GzipDecompressor gzip
+	GzipDecompressor Decompressor = func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	}
+	// DeflateDecompressor deflate，兼容没有 zlib 头的原始 deflate 数据
+	DeflateDecompressor Decompressor = func(r io.Reader) (io.ReadCloser, error) {
+		br := bufio.NewReader(r)
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		// zlib 头：CMF 低4位为8，且 CMF*256+FLG 能被31整除
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+	// BrotliDecompressor br
+	BrotliDecompressor Decompressor = func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	}
+	// GzipCompressor gzip
+	GzipCompressor Compressor = func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	}
+	// DeflateCompressor deflate，使用 zlib 格式
+	DeflateCompressor Compressor = func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriter(w), nil
+	}
+	// BrotliCompressor br
+	BrotliCompressor Compressor = func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriter(w), nil
+	}
+)
+
+var (
+	compressionLock sync.RWMutex
+	// decodingOrder Accept-Encoding 中的编码顺序，按注册顺序排列
+	decodingOrder = []string{"gzip", "deflate", "br"}
+	decompressors = map[string]Decompressor{
+		"gzip":    GzipDecompressor,
+		"deflate": DeflateDecompressor,
+		"br":      BrotliDecompressor,
+	}
+	compressors = map[string]Compressor{
+		"gzip":    GzipCompressor,
+		"deflate": DeflateCompressor,
+		"br":      BrotliCompressor,
+	}
+)
+
+// RegisterDecompressor 注册响应体的解压方式，注册后会加入请求的 Accept-Encoding，已存在时覆盖，
+// 内置 gzip、deflate、br，Zstandard 可以通过 github.com/klauspost/compress/zstd 注册：
+//
+//	restgo.RegisterDecompressor("zstd", func(r io.Reader) (io.ReadCloser, error) {
+//		decoder, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return decoder.IOReadCloser(), nil
+//	})
+func RegisterDecompressor(encoding string, decompressor Decompressor) {
+	encoding = strings.ToLower(encoding)
+	compressionLock.Lock()
+	defer compressionLock.Unlock()
+	if _, ok := decompressors[encoding]; !ok {
+		decodingOrder = append(decodingOrder, encoding)
+	}
+	decompressors[encoding] = decompressor
+}
+
+// RegisterCompressor 注册请求体的压缩方式，已存在时覆盖
+func RegisterCompressor(encoding string, compressor Compressor) {
+	compressionLock.Lock()
+	defer compressionLock.Unlock()
+	compressors[strings.ToLower(encoding)] = compressor
+}
+
+// acceptEncoding 已注册的解压方式，用于请求头 Accept-Encoding
+func acceptEncoding() string {
+	compressionLock.RLock()
+	defer compressionLock.RUnlock()
+	return strings.Join(decodingOrder, ", ")
+}
+
+func lookupDecompressor(encoding string) (Decompressor, bool) {
+	compressionLock.RLock()
+	defer compressionLock.RUnlock()
+	decompressor, ok := decompressors[strings.ToLower(strings.TrimSpace(encoding))]
+	return decompressor, ok
+}
+
+func lookupCompressor(encoding string) (Compressor, bool) {
+	compressionLock.RLock()
+	defer compressionLock.RUnlock()
+	compressor, ok := compressors[strings.ToLower(encoding)]
+	return compressor, ok
+}
+
+// KeepCompressed 保留压缩的原始响应体，不根据 Content-Encoding 解压
+func (builder *Builder) KeepCompressed() *Builder {
+	builder.keepCompressed = true
+	return builder
+}
+
+// CompressRequest 使用指定的编码压缩请求体，并设置 Content-Encoding，请求体为空时不压缩，编码需要通过 RegisterCompressor 注册，内置 gzip、deflate、br
+func (builder *Builder) CompressRequest(encoding string) *Builder {
+	builder.requestEncoding = encoding
+	return builder
+}
+
+// compressBody 边压缩边发送请求体，压缩后长度未知，使用分块传输，返回实际使用的 Content-Encoding，请求体为空时不压缩
+func (builder *Builder) compressBody(body io.Reader) (io.Reader, string, error) {
+	if builder.requestEncoding == "" {
+		return body, "", nil
+	}
+	compressor, ok := lookupCompressor(builder.requestEncoding)
+	if !ok {
+		return nil, "", fmt.Errorf("content-encoding:[%s] not support", builder.requestEncoding)
+	}
+	rb, ok := body.(*requestBody)
+	if !ok {
+		rb = newReaderBody(body)
+	}
+	if rb.length == 0 {
+		return body, "", nil
+	}
+	var sent int32
+	compressed := newPipeBody(-1, func(w io.Writer) error {
+		reader := io.Reader(rb)
+		// 只有第一次发送使用原始请求体，重新发送时重新生成
+		if !atomic.CompareAndSwapInt32(&sent, 0, 1) {
+			rc, err := rb.getBody()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			reader = rc
+		}
+		cw, err := compressor(w)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(cw, reader); err != nil {
+			_ = cw.Close()
+			return err
+		}
+		return cw.Close()
+	}, rb.getBody != nil)
+	return compressed, builder.requestEncoding, nil
+}
+
+// compressedCurl 压缩后的请求体无法直接写在 curl 中，内存中的请求体压缩后以 base64 输出，通过标准输入还原后发送，
+// 返回 curl 之前的管道命令以及 curl 中的请求体参数，流式请求体无法在 curl 中还原，保持原样
+func compressedCurl(body io.Reader, encoding string, curlPayload string) (string, string, error) {
+	rb, ok := body.(*requestBody)
+	if encoding == "" || !ok || rb.data == nil {
+		return "", curlPayload, nil
+	}
+	compressor, ok := lookupCompressor(encoding)
+	if !ok {
+		return "", "", fmt.Errorf("content-encoding:[%s] not support", encoding)
+	}
+	var buf bytes.Buffer
+	cw, err := compressor(&buf)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err = cw.Write(rb.data); err != nil {
+		_ = cw.Close()
+		return "", "", err
+	}
+	if err = cw.Close(); err != nil {
+		return "", "", err
+	}
+	input := fmt.Sprintf("printf '%%s' '%s' | base64 -d | ", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return input, "--data-binary @-", nil
+}
+
+// negotiateEncoding 调用方没有指定 Accept-Encoding 时使用已注册的解压方式，Range 请求不压缩
+func negotiateEncoding(req *http.Request) {
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+}
+
+// decompressResponse 根据 Content-Encoding 解压响应体，与 http.Transport 自动解压 gzip 时的处理一致，
+// 解压后移除 Content-Encoding、Content-Length
+func decompressResponse(rsp *http.Response) {
+	encoding := rsp.Header.Get("Content-Encoding")
+	if encoding == "" || rsp.Body == nil || rsp.Body == http.NoBody {
+		return
+	}
+	if rsp.Request != nil && rsp.Request.Method == http.MethodHead {
+		return
+	}
+	decompressor, ok := lookupDecompressor(encoding)
+	if !ok {
+		return
+	}
+	rsp.Body = &decompressReader{body: rsp.Body, decompressor: decompressor}
+	rsp.Header.Del("Content-Encoding")
+	rsp.Header.Del("Content-Length")
+	rsp.ContentLength = -1
+	rsp.Uncompressed = true
+}
+
+// decompressReader 第一次读取时才创建解压器，响应体为空时直接返回 io.EOF
+type decompressReader struct {
+	body         io.ReadCloser
+	decompressor Decompressor
+	reader       io.ReadCloser
+	err          error
+}
+
+func (r *decompressReader) Read(p []byte) (int, error) {
+	if r.reader == nil && r.err == nil {
+		br := bufio.NewReader(r.body)
+		if _, err := br.Peek(1); err != nil {
+			r.err = err
+		} else {
+			r.reader, r.err = r.decompressor(br)
+		}
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(p)
+}
+
+func (r *decompressReader) Close() error {
+	if r.reader != nil {
+		_ = r.reader.Close()
+	}
+	return r.body.Close()
+}
@@ -1,7 +1,6 @@
 package restgo
 
 import (
-	"bytes"
 	"context"
 	"io"
 	"net/http"
@@ -14,43 +13,58 @@ var client *http.Client
 
 var initOnce sync.Once
 
+// DefaultTimeout 普通请求默认的超时时间，请求的 context 已经设置了 deadline 时不生效
+const DefaultTimeout = 15 * time.Second
+
 type defaultRestGo struct {
-	client *http.Client
-	trace  *httptrace.ClientTrace
+	client  *http.Client
+	trace   *httptrace.ClientTrace
+	timeout time.Duration
 }
 
-// 配置参考：https://xujiahua.github.io/posts/20200723-golang-http-reuse/
+// initClient 全局共享的客户端，不设置 http.Client.Timeout，
+// 普通请求的超时由 DefaultTimeout 或 Builder.Timeout 控制，避免限制流式请求的总时长
 func initClient() *http.Client {
 	initOnce.Do(func() {
 		client = &http.Client{
-			Timeout: time.Duration(15) * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost:   512,
-				MaxConnsPerHost:       512,
-				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
+			Transport: NewTransport(),
 		}
 	})
 	return client
 }
 
-var defaultRestGoInstance RestGo = NewDefaultRestGo(initClient(), nil)
+var defaultRestGoInstance RestGo = &defaultRestGo{client: initClient(), timeout: DefaultTimeout}
 
 func NewDefaultRestGo(cli *http.Client, trace *httptrace.ClientTrace) *defaultRestGo {
 	return &defaultRestGo{client: cli, trace: trace}
 }
 
 func (d *defaultRestGo) Do(ctx context.Context, url string, method string,
-	body *bytes.Buffer, contentType string, headers map[string]string) (Response, error) {
+	body io.Reader, contentType string, headers map[string]string) (Response, error) {
 	var rsp *http.Response
 	var err error
 	var req *http.Request
 	if d.trace != nil {
 		ctx = httptrace.WithClientTrace(ctx, d.trace)
 	}
-	req, err = http.NewRequestWithContext(ctx, method, url, body)
+	cfg := requestConfigFrom(ctx)
+	var tracer *clientTracer
+	if cfg.trace {
+		tracer = newClientTracer()
+		ctx = tracer.withTrace(ctx)
+	}
+	cancel := context.CancelFunc(func() {})
+	if _, ok := ctx.Deadline(); !ok && d.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+	}
+	// RawResponse 模式下响应体关闭时才结束超时控制
+	raw := false
+	defer func() {
+		if !raw {
+			cancel()
+		}
+	}()
+	req, err = newHTTPRequest(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -61,20 +75,49 @@ func (d *defaultRestGo) Do(ctx context.Context, url string, method string,
 			req.Header.Add(k, v)
 		}
 	}
-	rsp, err = d.client.Do(req)
+	negotiateEncoding(req)
+	start := time.Now()
+	rsp, err = cfg.httpClient(d.client).Do(req)
 
 	if err != nil {
 		return nil, err
 	}
 
+	cfg.wrapResponseBody(rsp)
+	if cfg.rawResponse && (cfg.output == nil || !cfg.output.accept(rsp.StatusCode)) {
+		raw = true
+		iResp := newRawResponse(rsp, &cancelReadCloser{ReadCloser: rsp.Body, cancel: cancel}, cfg.maxBodySize, time.Since(start))
+		iResp.charset = cfg.charset
+		if tracer != nil {
+			iResp.traceInfo = tracer.traceInfo(time.Time{})
+		}
+		return iResp, nil
+	}
 	defer rsp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(rsp.Body)
+	if cfg.output != nil && cfg.output.accept(rsp.StatusCode) {
+		if err = cfg.output.save(rsp.StatusCode, rsp.Header.Get, rsp.Body); err != nil {
+			return nil, err
+		}
+		iResp := &IResponse{response: rsp, duration: time.Since(start), charset: cfg.charset}
+		if tracer != nil {
+			iResp.traceInfo = tracer.traceInfo(time.Now())
+		}
+		return iResp, nil
+	}
+
+	bodyBytes, err := readBody(rsp.Body, cfg.maxBodySize)
 	if err != nil {
 		return nil, err
 	}
-	return &IResponse{
+	iResp := &IResponse{
 		respBody: bodyBytes,
 		response: rsp,
-	}, nil
+		duration: time.Since(start),
+		charset:  cfg.charset,
+	}
+	if tracer != nil {
+		iResp.traceInfo = tracer.traceInfo(time.Now())
+	}
+	return iResp, nil
 }
@@ -1,14 +1,14 @@
 package restgo
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptrace"
-	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type defaultStreamRestGo struct {
@@ -23,25 +23,44 @@ func NewDefaultStreamRestGo(cli *http.Client, trace *httptrace.ClientTrace) *def
 }
 
 func (d *defaultStreamRestGo) DoStream(ctx context.Context, url string, method string,
-	body *bytes.Buffer, contentType string, headers map[string]string, callback func(resp StreamResponse, rspBody string) error) error {
+	body io.Reader, contentType string, headers map[string]string, callback StreamCallback) error {
 	var rsp *http.Response
 	var err error
 	var req *http.Request
 	if d.trace != nil {
 		ctx = httptrace.WithClientTrace(ctx, d.trace)
 	}
-	req, err = http.NewRequestWithContext(ctx, method, url, body)
+	cfg := requestConfigFrom(ctx)
+	idleTimeout := cfg.streamIdleTimeout
+	var idle *idleTimer
+	if idleTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		idle = newIdleTimer(idleTimeout, cancel)
+		defer idle.stop()
+	}
+	req, err = newHTTPRequest(ctx, method, url, body)
 	if err != nil {
 		return err
 	}
 	req.Header.Add("Content-Type", contentType)
-	req.Header.Set("Accept", "text/event-stream")
+	// 未指定解析器时按 SSE 协商，指定了解析器或者调用方设置了 Accept 时以调用方为准
+	if cfg.streamDecoder == nil {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
 	if headers != nil {
 		for k, v := range headers {
+			if http.CanonicalHeaderKey(k) == "Accept" {
+				req.Header.Set(k, v)
+				continue
+			}
 			req.Header.Add(k, v)
 		}
 	}
+	negotiateEncoding(req)
+	start := time.Now()
 	rsp, err = d.client.Do(req)
 
 	if err != nil {
@@ -49,52 +68,92 @@ func (d *defaultStreamRestGo) DoStream(ctx context.Context, url string, method s
 	}
 
 	defer rsp.Body.Close()
-	var streamResponse = &IStreamResponse{response: rsp}
-	return d.streamHandler(rsp, streamResponse, callback)
+	if idle != nil {
+		rsp.Body = &idleTimeoutReader{ReadCloser: rsp.Body, timer: idle}
+	}
+	cfg.wrapResponseBody(rsp)
+	var streamResponse = &IStreamResponse{response: rsp, duration: time.Since(start)}
+	err = d.streamHandler(rsp, streamResponse, cfg, callback)
+	if err != nil && idle != nil && idle.expired() {
+		return ErrStreamIdleTimeout
+	}
+	return err
 }
 
-func (d *defaultStreamRestGo) streamHandler(resp *http.Response, streamResponse StreamResponse, callback func(resp StreamResponse, rspBody string) error) error {
+func (d *defaultStreamRestGo) streamHandler(resp *http.Response, streamResponse StreamResponse, cfg *requestConfig, callback StreamCallback) error {
 	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return fmt.Errorf("error: %s, read rsp body failed", resp.Status)
 		}
-		return fmt.Errorf("error: %s, body: %s\n", resp.Status, body)
+		return newHTTPError(resp, body, "", "")
 	}
 
-	// 创建读取器来逐行读取
-	reader := bufio.NewReader(resp.Body)
+	if cfg.onStreamResponse != nil {
+		cfg.onStreamResponse(streamResponse)
+	}
 
+	// 未指定解析器时根据响应的 Content-Type 选择
+	factory := cfg.streamDecoder
+	if factory == nil {
+		factory = lookupStreamDecoder(resp.Header.Get("Content-Type"))
+	}
+	decoder := factory(resp.Body)
 	for {
-		line, err := reader.ReadString('\n')
+		event, err := decoder.Decode()
 		if err != nil {
 			if err == io.EOF {
-				break
+				return nil
 			}
 			return err
 		}
+		if err = callback(streamResponse, event); err != nil {
+			return err
+		}
+	}
+}
 
-		// 去除首尾空白字符
-		line = strings.TrimSpace(line)
+// ErrStreamIdleTimeout 流式请求在 StreamIdleTimeout 时间内没有收到任何数据
+var ErrStreamIdleTimeout = errors.New("restgo: stream idle timeout")
 
-		// 跳过空行和注释行
-		if line == "" || strings.HasPrefix(line, ":") {
-			continue
-		}
+// idleTimer 空闲计时器，每次收到数据时重置，超时后取消请求
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+	fired   int32
+}
 
-		// 解析 SSE 格式：data: {...}
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
+func newIdleTimer(timeout time.Duration, onExpire func()) *idleTimer {
+	t := &idleTimer{timeout: timeout}
+	t.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&t.fired, 1)
+		onExpire()
+	})
+	return t
+}
 
-			// 检查是否结束
-			if data == "[DONE]" {
-				break
-			}
-			if err = callback(streamResponse, data); err != nil {
-				return err
-			}
-		}
+func (t *idleTimer) reset() {
+	t.timer.Reset(t.timeout)
+}
+
+func (t *idleTimer) stop() {
+	t.timer.Stop()
+}
+
+func (t *idleTimer) expired() bool {
+	return atomic.LoadInt32(&t.fired) == 1
+}
+
+type idleTimeoutReader struct {
+	io.ReadCloser
+	timer *idleTimer
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && !r.timer.expired() {
+		r.timer.reset()
 	}
-	return nil
+	return n, err
 }
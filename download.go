@@ -0,0 +1,231 @@
+package restgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksumMismatch 下载内容的校验和与期望值不一致
+var ErrChecksumMismatch = errors.New("restgo: checksum mismatch")
+
+// partSuffix 下载过程中的临时文件后缀，下载完成并校验通过后重命名为目标文件
+const partSuffix = ".part"
+
+// OutputFile 将响应体直接写入文件，不读入内存，下载过程中写入 path.part，完成后原子重命名为 path，
+// 响应状态码不是2xx时返回 HTTPError
+func (builder *Builder) OutputFile(path string) *Builder {
+	builder.outputPath = path
+	return builder
+}
+
+// OutputWriter 将响应体直接写入 w，不读入内存，响应状态码不是2xx时返回 HTTPError
+func (builder *Builder) OutputWriter(w io.Writer) *Builder {
+	builder.outputWriter = w
+	return builder
+}
+
+// ExpectSHA256 校验下载内容的 SHA-256，sum 为十六进制字符串，不一致时返回 ErrChecksumMismatch，
+// 写入文件时会删除临时文件
+func (builder *Builder) ExpectSHA256(sum string) *Builder {
+	builder.expectSHA256 = strings.ToLower(sum)
+	return builder
+}
+
+// ResumeDownload 配合 OutputFile 使用，存在上次未完成的临时文件时通过 Range 请求继续下载，
+// 服务端不支持 Range 时重新下载
+func (builder *Builder) ResumeDownload() *Builder {
+	builder.resumeDownload = true
+	return builder
+}
+
+// outputTarget 响应体的写入目标
+type outputTarget struct {
+	path   string
+	writer io.Writer
+	// openWriter 根据响应头创建写入目标，用于写入前需要根据响应确定目标的场景
+	openWriter   func(header func(key string) string) (io.Writer, error)
+	expectSHA256 string
+	resume       bool
+	// offset 断点续传时已下载的长度
+	offset int64
+	saved  bool
+}
+
+func (builder *Builder) newOutputTarget() (*outputTarget, error) {
+	if builder.outputPath == "" && builder.outputWriter == nil {
+		return nil, nil
+	}
+	target := &outputTarget{
+		path:         builder.outputPath,
+		writer:       builder.outputWriter,
+		expectSHA256: builder.expectSHA256,
+		resume:       builder.resumeDownload && builder.outputPath != "",
+	}
+	if target.resume {
+		info, err := os.Stat(target.partPath())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil && info.Mode().IsRegular() {
+			target.offset = info.Size()
+		}
+	}
+	return target, nil
+}
+
+func (o *outputTarget) partPath() string {
+	return o.path + partSuffix
+}
+
+// rangeHeader 断点续传时请求剩余部分
+func (o *outputTarget) rangeHeader() string {
+	if o.offset <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("bytes=%d-", o.offset)
+}
+
+// accept 响应状态码为2xx，或者临时文件已经是完整内容时（416）写入目标
+func (o *outputTarget) accept(statusCode int) bool {
+	return isSuccess(statusCode) || (o.offset > 0 && statusCode == http.StatusRequestedRangeNotSatisfiable)
+}
+
+// save 将响应体写入目标并校验，header 用于获取响应头
+func (o *outputTarget) save(statusCode int, header func(key string) string, body io.Reader) error {
+	o.saved = true
+	hasher := sha256.New()
+	if o.path == "" {
+		writer := o.writer
+		if o.openWriter != nil {
+			var err error
+			if writer, err = o.openWriter(header); err != nil {
+				return err
+			}
+		}
+		if _, err := io.Copy(io.MultiWriter(writer, hasher), body); err != nil {
+			return err
+		}
+		return o.verify(hasher)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), os.ModePerm); err != nil {
+		return err
+	}
+	contentRange := header("Content-Range")
+	var file *os.File
+	var err error
+	switch {
+	case statusCode == http.StatusRequestedRangeNotSatisfiable:
+		// 服务端返回 bytes */total，临时文件长度与总长度一致时说明已下载完成
+		if total, ok := contentRangeTotal(contentRange); !ok || total != o.offset {
+			return fmt.Errorf("restgo: range not satisfiable, content-range [%s]", contentRange)
+		}
+		return o.finish(hasher)
+	case statusCode == http.StatusPartialContent && o.offset > 0:
+		if start, ok := contentRangeStart(contentRange); !ok || start != o.offset {
+			return fmt.Errorf("restgo: unexpected content-range [%s], expected start %d", contentRange, o.offset)
+		}
+		file, err = os.OpenFile(o.partPath(), os.O_WRONLY|os.O_APPEND, 0)
+	default:
+		// 服务端不支持 Range 时返回完整内容，从头开始写入
+		o.offset = 0
+		file, err = os.OpenFile(o.partPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, body); err != nil {
+		_ = file.Close()
+		// 支持断点续传时保留临时文件，下次继续下载
+		if !o.resume {
+			_ = os.Remove(o.partPath())
+		}
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return o.finish(hasher)
+}
+
+// finish 校验临时文件并重命名为目标文件
+func (o *outputTarget) finish(hasher hash.Hash) error {
+	if o.expectSHA256 != "" {
+		if err := hashFile(hasher, o.partPath()); err != nil {
+			return err
+		}
+		if err := o.verify(hasher); err != nil {
+			_ = os.Remove(o.partPath())
+			return err
+		}
+	}
+	return os.Rename(o.partPath(), o.path)
+}
+
+func (o *outputTarget) verify(hasher hash.Hash) error {
+	if o.expectSHA256 == "" {
+		return nil
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != o.expectSHA256 {
+		return fmt.Errorf("%w: expected sha256 %s, got %s", ErrChecksumMismatch, o.expectSHA256, sum)
+	}
+	return nil
+}
+
+func hashFile(hasher hash.Hash, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(hasher, file)
+	return err
+}
+
+// contentRangeStart 解析 Content-Range: bytes start-end/total 中的 start
+func contentRangeStart(contentRange string) (int64, bool) {
+	spec, ok := cutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	idx := strings.IndexByte(spec, '-')
+	if idx <= 0 {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(spec[:idx], 10, 64)
+	return start, err == nil
+}
+
+// contentRangeTotal 解析 Content-Range: bytes */total 中的 total
+func contentRangeTotal(contentRange string) (int64, bool) {
+	spec, ok := cutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	idx := strings.IndexByte(spec, '/')
+	if idx < 0 {
+		return 0, false
+	}
+	total, err := strconv.ParseInt(spec[idx+1:], 10, 64)
+	return total, err == nil
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
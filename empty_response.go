@@ -1,6 +1,13 @@
 package restgo
 
-import "errors"
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
 
 type EmptyResponse struct {
 	rsp any
@@ -18,6 +25,10 @@ func (e *EmptyResponse) Body() []byte {
 	return nil
 }
 
+func (e *EmptyResponse) BodyReader() io.ReadCloser {
+	return http.NoBody
+}
+
 func (e *EmptyResponse) BodyUnmarshal(v interface{}) error {
 	return nil
 }
@@ -42,6 +53,43 @@ func (e *EmptyResponse) ProtoMinor() int {
 	return 0
 }
 
+func (e *EmptyResponse) Headers() http.Header {
+	return nil
+}
+
+func (e *EmptyResponse) HeaderValues(key string) []string {
+	return nil
+}
+
+func (e *EmptyResponse) Cookies() []*http.Cookie {
+	return nil
+}
+
+func (e *EmptyResponse) Request() *http.Request {
+	return nil
+}
+
+func (e *EmptyResponse) URL() *url.URL {
+	return nil
+}
+
+// ContentLength 与 IResponse 一致，长度未知时为 -1
+func (e *EmptyResponse) ContentLength() int64 {
+	return -1
+}
+
+func (e *EmptyResponse) TLS() *tls.ConnectionState {
+	return nil
+}
+
+func (e *EmptyResponse) Duration() time.Duration {
+	return 0
+}
+
+func (e *EmptyResponse) TraceInfo() TraceInfo {
+	return TraceInfo{}
+}
+
 func (e *EmptyResponse) Rsp() (any, error) {
 	if e.rsp == nil {
 		return nil, errors.New("rsp struct not set")
@@ -0,0 +1,66 @@
+package restgo
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HTTPError 响应状态码不是2xx时返回的错误，需要通过 Builder.ExpectSuccess 或 Client.ExpectSuccess 开启
+// 可以通过 errors.As 获取
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Method     string
+	// URL 最终请求的地址，发生重定向时为重定向后的地址
+	URL string
+	// Response 原始响应
+	Response Response
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("restgo: %s %s: %s", e.Method, e.URL, e.Status)
+}
+
+// isSuccess 2xx状态码视为成功
+func isSuccess(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// newHTTPError 根据响应构造 HTTPError，method、url 为发起请求时的参数，能获取到底层请求时以底层请求为准
+func newHTTPError(rsp *http.Response, body []byte, method, url string) *HTTPError {
+	if rsp.Request != nil {
+		method = rsp.Request.Method
+		if rsp.Request.URL != nil {
+			url = rsp.Request.URL.String()
+		}
+	}
+	return &HTTPError{
+		StatusCode: rsp.StatusCode,
+		Status:     rsp.Status,
+		Header:     rsp.Header,
+		Body:       body,
+		Method:     method,
+		URL:        url,
+	}
+}
+
+// responseError 把非2xx的 Response 转换为 HTTPError
+func responseError(respW Response, method, url string) *HTTPError {
+	var httpErr *HTTPError
+	if raw, ok := respW.(interface{ httpResponse() *http.Response }); ok && raw.httpResponse() != nil {
+		httpErr = newHTTPError(raw.httpResponse(), respW.Body(), method, url)
+	} else {
+		httpErr = &HTTPError{
+			StatusCode: respW.StatusCode(),
+			Status:     respW.Status(),
+			Header:     make(http.Header),
+			Body:       respW.Body(),
+			Method:     method,
+			URL:        url,
+		}
+	}
+	httpErr.Response = respW
+	return httpErr
+}
@@ -0,0 +1,61 @@
+package restgo
+
+import (
+	"context"
+	"errors"
+)
+
+// APIError 响应状态码不是2xx时返回的错误，Detail 为按 E 反序列化后的错误响应体
+// 通过 errors.As 既可以获取 *APIError[E]，也可以获取内部的 *HTTPError
+type APIError[E any] struct {
+	*HTTPError
+	Detail E
+}
+
+func (e *APIError[E]) Unwrap() error {
+	return e.HTTPError
+}
+
+// Do 发送请求并将响应体反序列化为 T
+//
+//	user, rsp, err := restgo.Do[User](client.R().PathVariable(pv), restgo.GET, "/user/:id")
+func Do[T any](builder *Builder, method HttpMethod, url string) (T, Response, error) {
+	return CtxDo[T](context.Background(), builder, method, url)
+}
+
+func CtxDo[T any](ctx context.Context, builder *Builder, method HttpMethod, url string) (T, Response, error) {
+	var result T
+	rsp, err := builder.RspUnmarshal(&result).CtxSend(ctx, method, url)
+	return result, rsp, err
+}
+
+// DoE 发送请求并将响应体反序列化为 T，响应状态码不是2xx时将响应体反序列化为 E 并返回 *APIError[E]
+func DoE[T any, E any](builder *Builder, method HttpMethod, url string) (T, Response, error) {
+	return CtxDoE[T, E](context.Background(), builder, method, url)
+}
+
+func CtxDoE[T any, E any](ctx context.Context, builder *Builder, method HttpMethod, url string) (T, Response, error) {
+	var result T
+	var detail E
+	rsp, err := builder.RspUnmarshal(&result).ErrorUnmarshal(&detail).CtxSend(ctx, method, url)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return result, rsp, &APIError[E]{HTTPError: httpErr, Detail: detail}
+	}
+	return result, rsp, err
+}
+
+// Stream 发送流式请求，并将每条事件的数据反序列化为 T
+func Stream[T any](builder *Builder, method HttpMethod, url string, callback func(resp StreamResponse, item T) error) error {
+	return CtxStream[T](context.Background(), builder, method, url, callback)
+}
+
+func CtxStream[T any](ctx context.Context, builder *Builder, method HttpMethod, url string, callback func(resp StreamResponse, item T) error) error {
+	return builder.CtxStreamSendEvent(ctx, method, url, func(resp StreamResponse, event Event) error {
+		var item T
+		if err := event.Unmarshal(&item); err != nil {
+			return err
+		}
+		return callback(resp, item)
+	})
+}
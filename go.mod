@@ -2,6 +2,10 @@ module github.com/xiao-ren-wu/restgo
 
 go 1.18
 
-require github.com/avast/retry-go v3.0.0+incompatible
+require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/avast/retry-go v3.0.0+incompatible
+	golang.org/x/text v0.14.0
+)
 
 require github.com/stretchr/testify v1.8.2 // indirect
@@ -1,13 +1,94 @@
 package restgo
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 )
 
+// ErrBodyTooLarge 响应体超过 MaxBodySize 设置的长度
+var ErrBodyTooLarge = errors.New("restgo: response body too large")
+
 type IResponse struct {
-	respBody []byte
-	response *http.Response
+	respBody  []byte
+	response  *http.Response
+	duration  time.Duration
+	traceInfo TraceInfo
+	charset   charsetOptions
+
+	// body RawResponse 模式下未读取的响应体，首次调用 Body 时才读取到内存
+	body        io.ReadCloser
+	maxBodySize int64
+	bodyErr     error
+	bodyLock    sync.Mutex
+}
+
+// newRawResponse 不读取响应体，由调用方通过 BodyReader 读取或者调用 Body 时再读取
+func newRawResponse(rsp *http.Response, body io.ReadCloser, maxBodySize int64, duration time.Duration) *IResponse {
+	return &IResponse{response: rsp, body: body, maxBodySize: maxBodySize, duration: duration}
+}
+
+// attachCancel 响应体关闭时调用 cancel，响应体已经读取到内存时返回 false
+func (wrapper *IResponse) attachCancel(cancel context.CancelFunc) bool {
+	wrapper.bodyLock.Lock()
+	defer wrapper.bodyLock.Unlock()
+	if wrapper.body == nil {
+		return false
+	}
+	wrapper.body = &cancelReadCloser{ReadCloser: wrapper.body, cancel: cancel}
+	return true
+}
+
+// cancelReadCloser 关闭时同时取消请求对应的 context
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
+
+// readBody 读取响应体，maxBodySize 大于0时超过该长度返回 ErrBodyTooLarge
+func readBody(reader io.Reader, maxBodySize int64) ([]byte, error) {
+	if maxBodySize <= 0 {
+		return io.ReadAll(reader)
+	}
+	body, err := io.ReadAll(io.LimitReader(reader, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBodySize {
+		return nil, fmt.Errorf("%w: limit %d bytes", ErrBodyTooLarge, maxBodySize)
+	}
+	return body, nil
+}
+
+// NewResponse 构造一个不经过网络的响应，常用于中间件短路返回或测试桩
+func NewResponse(statusCode int, header http.Header, body []byte) Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &IResponse{
+		respBody: body,
+		response: &http.Response{
+			Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+			StatusCode: statusCode,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+		},
+	}
 }
 
 func (wrapper *IResponse) Header(key string) string {
@@ -19,16 +100,69 @@ func (wrapper *IResponse) Header(key string) string {
 	return ""
 }
 
+// BodyStr 根据响应的字符集转换为 UTF-8，无法转换时返回原始内容
 func (wrapper *IResponse) BodyStr() string {
-	return string(wrapper.respBody)
+	body, err := wrapper.textBody()
+	if err != nil {
+		return string(wrapper.Body())
+	}
+	return string(body)
+}
+
+// textBody 读取响应体并转换为 UTF-8
+func (wrapper *IResponse) textBody() ([]byte, error) {
+	body, err := wrapper.readBody()
+	if err != nil {
+		return nil, err
+	}
+	return decodeCharset(wrapper.Header("Content-Type"), body, wrapper.charset)
 }
 
+// Body RawResponse 模式下首次调用时读取响应体，读取失败或者已经通过 BodyReader 读取时返回 nil，
+// 失败原因可以通过 BodyUnmarshal 获取
 func (wrapper *IResponse) Body() []byte {
-	return wrapper.respBody
+	body, _ := wrapper.readBody()
+	return body
 }
 
+func (wrapper *IResponse) readBody() ([]byte, error) {
+	wrapper.bodyLock.Lock()
+	defer wrapper.bodyLock.Unlock()
+	if wrapper.body != nil {
+		wrapper.respBody, wrapper.bodyErr = readBody(wrapper.body, wrapper.maxBodySize)
+		_ = wrapper.body.Close()
+		wrapper.body = nil
+	}
+	return wrapper.respBody, wrapper.bodyErr
+}
+
+// BodyReader 获取响应体的 reader，使用完毕后必须关闭。RawResponse 模式下为未读取的原始响应体，只能读取一次，
+// 读取后 Body 返回 nil；其他情况下读取已缓存的响应体
+func (wrapper *IResponse) BodyReader() io.ReadCloser {
+	wrapper.bodyLock.Lock()
+	defer wrapper.bodyLock.Unlock()
+	if wrapper.body != nil {
+		body := wrapper.body
+		wrapper.body = nil
+		wrapper.bodyErr = errBodyConsumed
+		return body
+	}
+	return io.NopCloser(bytes.NewReader(wrapper.respBody))
+}
+
+var errBodyConsumed = errors.New("restgo: response body already consumed by BodyReader")
+
+// BodyUnmarshal 根据响应的 Content-Type 选择编解码器反序列化响应体，无法识别时按 JSON 处理
 func (wrapper *IResponse) BodyUnmarshal(v interface{}) error {
-	return json.Unmarshal(wrapper.respBody, v)
+	body, err := wrapper.textBody()
+	if err != nil {
+		return err
+	}
+	return unmarshalBody(wrapper.Header("Content-Type"), body, v, nil)
+}
+
+func (wrapper *IResponse) httpResponse() *http.Response {
+	return wrapper.response
 }
 
 // Status 获取http状态码
@@ -51,3 +185,67 @@ func (wrapper *IResponse) ProtoMajor() int {
 func (wrapper *IResponse) ProtoMinor() int {
 	return wrapper.response.ProtoMinor
 }
+
+func (wrapper *IResponse) Headers() http.Header {
+	if wrapper == nil || wrapper.response == nil {
+		return nil
+	}
+	return wrapper.response.Header
+}
+
+func (wrapper *IResponse) HeaderValues(key string) []string {
+	if wrapper == nil || wrapper.response == nil {
+		return nil
+	}
+	return wrapper.response.Header.Values(key)
+}
+
+func (wrapper *IResponse) Cookies() []*http.Cookie {
+	if wrapper == nil || wrapper.response == nil {
+		return nil
+	}
+	return wrapper.response.Cookies()
+}
+
+func (wrapper *IResponse) Request() *http.Request {
+	if wrapper == nil || wrapper.response == nil {
+		return nil
+	}
+	return wrapper.response.Request
+}
+
+func (wrapper *IResponse) URL() *url.URL {
+	if wrapper == nil || wrapper.response == nil || wrapper.response.Request == nil {
+		return nil
+	}
+	return wrapper.response.Request.URL
+}
+
+func (wrapper *IResponse) ContentLength() int64 {
+	if wrapper == nil || wrapper.response == nil {
+		return -1
+	}
+	return wrapper.response.ContentLength
+}
+
+func (wrapper *IResponse) TLS() *tls.ConnectionState {
+	if wrapper == nil || wrapper.response == nil {
+		return nil
+	}
+	return wrapper.response.TLS
+}
+
+func (wrapper *IResponse) Duration() time.Duration {
+	if wrapper == nil {
+		return 0
+	}
+	return wrapper.duration
+}
+
+// TraceInfo 请求各阶段耗时，没有开启 EnableTrace 时为零值
+func (wrapper *IResponse) TraceInfo() TraceInfo {
+	if wrapper == nil {
+		return TraceInfo{}
+	}
+	return wrapper.traceInfo
+}
@@ -1,9 +1,15 @@
 package restgo
 
-import "net/http"
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
 
 type IStreamResponse struct {
 	response *http.Response
+	duration time.Duration
 }
 
 func (sr *IStreamResponse) Header(key string) string {
@@ -47,3 +53,59 @@ func (sr *IStreamResponse) ProtoMinor() int {
 	}
 	return sr.response.ProtoMinor
 }
+
+func (sr *IStreamResponse) Headers() http.Header {
+	if sr == nil || sr.response == nil {
+		return nil
+	}
+	return sr.response.Header
+}
+
+func (sr *IStreamResponse) HeaderValues(key string) []string {
+	if sr == nil || sr.response == nil {
+		return nil
+	}
+	return sr.response.Header.Values(key)
+}
+
+func (sr *IStreamResponse) Cookies() []*http.Cookie {
+	if sr == nil || sr.response == nil {
+		return nil
+	}
+	return sr.response.Cookies()
+}
+
+func (sr *IStreamResponse) Request() *http.Request {
+	if sr == nil || sr.response == nil {
+		return nil
+	}
+	return sr.response.Request
+}
+
+func (sr *IStreamResponse) URL() *url.URL {
+	if sr == nil || sr.response == nil || sr.response.Request == nil {
+		return nil
+	}
+	return sr.response.Request.URL
+}
+
+func (sr *IStreamResponse) ContentLength() int64 {
+	if sr == nil || sr.response == nil {
+		return -1
+	}
+	return sr.response.ContentLength
+}
+
+func (sr *IStreamResponse) TLS() *tls.ConnectionState {
+	if sr == nil || sr.response == nil {
+		return nil
+	}
+	return sr.response.TLS
+}
+
+func (sr *IStreamResponse) Duration() time.Duration {
+	if sr == nil {
+		return 0
+	}
+	return sr.duration
+}
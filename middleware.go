@@ -0,0 +1,43 @@
+package restgo
+
+import (
+	"context"
+	"io"
+)
+
+// Middleware 包装 RestGo 的中间件，可在请求前后做鉴权、日志、监控等横切逻辑，
+// 也可以不调用 next 直接返回一个构造出来的 Response 实现短路
+type Middleware func(next RestGo) RestGo
+
+// StreamMiddleware 包装 StreamRestGo 的中间件，作用同 Middleware
+type StreamMiddleware func(next StreamRestGo) StreamRestGo
+
+// RestGoFunc 函数形式的 RestGo，方便编写中间件
+type RestGoFunc func(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error)
+
+func (f RestGoFunc) Do(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error) {
+	return f(ctx, url, method, body, contentType, headers)
+}
+
+// StreamRestGoFunc 函数形式的 StreamRestGo，方便编写中间件
+type StreamRestGoFunc func(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string, callback StreamCallback) error
+
+func (f StreamRestGoFunc) DoStream(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string, callback StreamCallback) error {
+	return f(ctx, url, method, body, contentType, headers, callback)
+}
+
+// chainRestGo 按注册顺序组装中间件，先注册的位于最外层，最先执行
+func chainRestGo(restGo RestGo, middlewares []Middleware) RestGo {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		restGo = middlewares[i](restGo)
+	}
+	return restGo
+}
+
+// chainStreamRestGo 按注册顺序组装流式中间件，先注册的位于最外层，最先执行
+func chainStreamRestGo(streamRestGo StreamRestGo, middlewares []StreamMiddleware) StreamRestGo {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		streamRestGo = middlewares[i](streamRestGo)
+	}
+	return streamRestGo
+}
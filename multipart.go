@@ -0,0 +1,304 @@
+package restgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FormFile multipart 表单中上传的文件，Path 与 Reader 二选一
+type FormFile struct {
+	// Key 表单字段名，多个文件可以使用相同的字段名
+	Key string
+	// FileName 文件名，为空时取 Path 中的文件名
+	FileName string
+	// Path 本地文件路径或者 http 下载链接
+	Path string
+	// Reader 文件内容，实现了 io.Seeker 时重定向、重试可以重新发送
+	Reader io.Reader
+	// ContentType 文件的 Content-Type，为空时为 application/octet-stream
+	ContentType string
+	// Header 该部分额外的头信息
+	Header map[string]string
+	// source 添加时记录 Reader 的读取位置
+	source *readerSource
+}
+
+// FormFiles 追加上传的文件，可与 File、FileReader 混合使用，按添加顺序写入表单
+func (builder *Builder) FormFiles(files ...FormFile) *Builder {
+	for _, file := range files {
+		if file.Reader != nil {
+			file.source = newReaderSource(file.Reader)
+		}
+		builder.formFiles = append(builder.formFiles, file)
+	}
+	return builder
+}
+
+// generateFormDataFileWriter 文件内容在发送请求时边读边写入请求体，不会整体读入内存
+func (builder *Builder) generateFormDataFileWriter(ctx context.Context) (io.Reader, string, string, error) {
+	keys, fields, err := formFields(builder.body)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	files := make([]*formDataFile, 0, len(builder.formFiles))
+	for _, formFile := range builder.formFiles {
+		file, err := builder.formDataFile(ctx, formFile)
+		if err != nil {
+			return nil, "", "", err
+		}
+		files = append(files, file)
+	}
+
+	// 固定分隔符，保证重新生成的请求体与计算长度时一致
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	writeForm := func(w io.Writer, withContent bool) error {
+		writer := multipart.NewWriter(w)
+		if err := writer.SetBoundary(boundary); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			for _, val := range fields[key] {
+				if err := writer.WriteField(key, val); err != nil {
+					return err
+				}
+			}
+		}
+		for _, file := range files {
+			if withContent && file.writePart != nil {
+				if err := file.writePart(writer); err != nil {
+					return err
+				}
+				continue
+			}
+			partWriter, err := writer.CreatePart(file.header)
+			if err != nil {
+				return err
+			}
+			if withContent {
+				if err = file.copyTo(partWriter); err != nil {
+					return err
+				}
+			}
+		}
+		return writer.Close()
+	}
+
+	// 所有文件长度已知时才能计算出请求体长度，否则使用分块传输
+	length := int64(0)
+	rewindable := true
+	for _, file := range files {
+		if length >= 0 && file.size >= 0 {
+			length += file.size
+		} else {
+			length = -1
+		}
+		rewindable = rewindable && file.rewindable
+	}
+	if length >= 0 {
+		counter := &countWriter{}
+		if err = writeForm(counter, false); err != nil {
+			return nil, "", "", err
+		}
+		length += counter.n
+	}
+	payload := newPipeBody(length, func(w io.Writer) error {
+		return writeForm(w, true)
+	}, rewindable)
+
+	contentType := "multipart/form-data; boundary=" + boundary
+	return payload, contentType, builder.generateFormDataPayloadCurl(keys, fields), nil
+}
+
+// formDataFile 写入表单的文件，size 为 -1 时表示长度未知
+type formDataFile struct {
+	header     textproto.MIMEHeader
+	size       int64
+	rewindable bool
+	copyTo     func(w io.Writer) error
+	// writePart 不为空时由其自行创建表单中的文件部分，用于需要根据下载结果确定头信息的场景
+	writePart func(writer *multipart.Writer) error
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (builder *Builder) formDataFile(ctx context.Context, formFile FormFile) (*formDataFile, error) {
+	if formFile.Path == "" && formFile.Reader == nil {
+		return nil, fmt.Errorf("form file [%s] path or reader must be set", formFile.Key)
+	}
+	if formFile.Reader == nil && isRemoteFile(formFile.Path) {
+		return builder.remoteFormDataFile(ctx, formFile)
+	}
+	fileName := formFile.FileName
+	if fileName == "" {
+		fileName = formFile.Path
+	}
+	header := formFilePartHeader(formFile, fileName, formFile.ContentType)
+	file := &formDataFile{header: header}
+
+	if formFile.Reader != nil {
+		source := formFile.source
+		if source == nil {
+			source = newReaderSource(formFile.Reader)
+		}
+		if err := source.reset(); err != nil {
+			return nil, err
+		}
+		file.size = source.size
+		file.rewindable = source.rewindable()
+		file.copyTo = func(w io.Writer) error {
+			if file.rewindable {
+				if err := source.rewind(); err != nil {
+					return err
+				}
+			}
+			_, err := io.Copy(w, formFile.Reader)
+			return err
+		}
+		return file, nil
+	}
+
+	filePath := formFile.Path
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	file.size = info.Size()
+	file.rewindable = true
+	file.copyTo = func(w io.Writer) error {
+		return copyFile(w, filePath)
+	}
+	return file, nil
+}
+
+// formFilePartHeader 表单中文件部分的头信息，contentType 为空时为 application/octet-stream
+func formFilePartHeader(formFile FormFile, fileName, contentType string) textproto.MIMEHeader {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := make(textproto.MIMEHeader)
+	for k, v := range formFile.Header {
+		header.Set(k, v)
+	}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(formFile.Key), quoteEscaper.Replace(filepath.Base(fileName))))
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			fmt.Printf("close file failed, fail info: %v\n", err)
+		}
+	}(file)
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// formFields 将载荷转换为表单字段，返回排序后的字段名，
+// 字符串原样发送，数字、布尔值格式化为字符串，数组作为同名的多个字段，对象以 JSON 发送，null 忽略
+func formFields(body interface{}) ([]string, url.Values, error) {
+	fields := make(url.Values)
+	if body == nil {
+		return nil, fields, nil
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(bodyBytes))
+	decoder.UseNumber()
+	param := make(map[string]interface{})
+	if err = decoder.Decode(&param); err != nil {
+		return nil, nil, fmt.Errorf("form payload must be an object: %w", err)
+	}
+	for key, val := range param {
+		items, ok := val.([]interface{})
+		if !ok {
+			items = []interface{}{val}
+		}
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			s, err := formFieldValue(item)
+			if err != nil {
+				return nil, nil, err
+			}
+			fields.Add(key, s)
+		}
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, fields, nil
+}
+
+func formFieldValue(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	b, err := json.Marshal(val)
+	return string(b), err
+}
+
+func (builder *Builder) generateFormDataPayloadCurl(keys []string, fields url.Values) string {
+	if builder.curlConsumerFunc == nil {
+		return ""
+	}
+	var buf strings.Builder
+	for _, k := range keys {
+		for _, v := range fields[k] {
+			buf.WriteString(fmt.Sprintf("--form '%s=\"%s\"' \\\n", shellQuoteEscape(k), shellQuoteEscape(v)))
+		}
+	}
+	for _, file := range builder.formFiles {
+		fileName := file.Path
+		if fileName == "" {
+			fileName = file.FileName
+		}
+		part := fmt.Sprintf("%s=@\"%s\"", file.Key, fileName)
+		if file.FileName != "" && file.Path != "" {
+			part += fmt.Sprintf(";filename=\"%s\"", file.FileName)
+		}
+		if file.ContentType != "" {
+			part += ";type=" + file.ContentType
+		}
+		headerKeys := make([]string, 0, len(file.Header))
+		for k := range file.Header {
+			headerKeys = append(headerKeys, k)
+		}
+		sort.Strings(headerKeys)
+		for _, k := range headerKeys {
+			part += fmt.Sprintf(";headers=\"%s: %s\"", k, file.Header[k])
+		}
+		buf.WriteString(fmt.Sprintf("--form '%s' \\\n", shellQuoteEscape(part)))
+	}
+	return buf.String()
+}
@@ -0,0 +1,80 @@
+package restgo
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PatchOperation JSON Patch 中的一个操作，见 RFC 6902
+type PatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// MarshalJSON add、replace、test 操作必须携带 value，即使为 null；move、copy 操作必须携带 from
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{
+		"op":   op.Op,
+		"path": op.Path,
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		fields["value"] = op.Value
+	case "move", "copy":
+		fields["from"] = op.From
+	}
+	return json.Marshal(fields)
+}
+
+func PatchAdd(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: "add", Path: path, Value: value}
+}
+
+func PatchRemove(path string) PatchOperation {
+	return PatchOperation{Op: "remove", Path: path}
+}
+
+func PatchReplace(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: "replace", Path: path, Value: value}
+}
+
+func PatchMove(from, path string) PatchOperation {
+	return PatchOperation{Op: "move", From: from, Path: path}
+}
+
+func PatchCopy(from, path string) PatchOperation {
+	return PatchOperation{Op: "copy", From: from, Path: path}
+}
+
+func PatchTest(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: "test", Path: path, Value: value}
+}
+
+// MergePatch 以 JSON Merge Patch 格式发送 patch，字段为 null 表示删除
+func (builder *Builder) MergePatch(patch interface{}) *Builder {
+	builder.contentType = MergePatchJson
+	builder.body = patch
+	return builder
+}
+
+// JSONPatch 以 JSON Patch 格式发送一组操作
+func (builder *Builder) JSONPatch(ops ...PatchOperation) *Builder {
+	builder.contentType = JsonPatchJson
+	builder.body = ops
+	return builder
+}
+
+// CorsPreflight 设置 CORS 预检请求所需的请求头，配合 OPTIONS 方法使用
+func (builder *Builder) CorsPreflight(origin string, method HttpMethod, headers ...string) *Builder {
+	if builder.headers == nil {
+		builder.headers = make(map[string]string)
+	}
+	builder.headers["Origin"] = origin
+	builder.headers["Access-Control-Request-Method"] = string(method)
+	if len(headers) > 0 {
+		builder.headers["Access-Control-Request-Headers"] = strings.Join(headers, ", ")
+	}
+	return builder
+}
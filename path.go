@@ -0,0 +1,98 @@
+package restgo
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+type pathParam struct {
+	val string
+	raw bool
+}
+
+// PathParam 设置单个路径参数，支持字符串、数字以及实现了 fmt.Stringer、encoding.TextMarshaler 的类型（如 UUID），
+// 参数值会通过 url.PathEscape 转义
+func (builder *Builder) PathParam(key string, val interface{}) *Builder {
+	return builder.setPathParam(key, val, false)
+}
+
+// RawPathParam 设置单个路径参数，参数值不做转义，适用于值本身就包含多级路径的场景
+func (builder *Builder) RawPathParam(key string, val interface{}) *Builder {
+	return builder.setPathParam(key, val, true)
+}
+
+func (builder *Builder) setPathParam(key string, val interface{}, raw bool) *Builder {
+	if builder.pathVal == nil {
+		builder.pathVal = make(map[string]pathParam)
+	}
+	str, err := formatParam(reflect.ValueOf(val))
+	if err != nil {
+		// 无法格式化的类型退化为默认格式
+		str = fmt.Sprint(val)
+	}
+	builder.pathVal[key] = pathParam{val: str, raw: raw}
+	return builder
+}
+
+// setPathVariable 替换路径参数，支持 /users/:id 形式的整段参数，以及 /users/{id}.json 形式可以出现在段内的参数
+// 存在未设置的参数时一次性返回所有缺失的参数名，没有设置任何路径参数时 :name 形式的段原样保留，只检查 {name} 形式的参数
+func (builder *Builder) setPathVariable(rawURL string) (string, error) {
+	colonParam := len(builder.pathVal) > 0
+	// 查询参数以及锚点部分不做替换
+	var suffix string
+	if idx := strings.IndexAny(rawURL, "?#"); idx >= 0 {
+		rawURL, suffix = rawURL[:idx], rawURL[idx:]
+	}
+
+	var missing []string
+	resolve := func(key string) string {
+		param, ok := builder.pathVal[key]
+		if !ok {
+			missing = append(missing, key)
+			return ""
+		}
+		if param.raw {
+			return param.val
+		}
+		return url.PathEscape(param.val)
+	}
+
+	subPaths := strings.Split(rawURL, "/")
+	for idx, subPath := range subPaths {
+		if colonParam && strings.HasPrefix(subPath, ":") {
+			subPaths[idx] = resolve(subPath[1:])
+			continue
+		}
+		subPaths[idx] = replaceBraces(subPath, resolve)
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("path val [%s] not set", strings.Join(missing, ", "))
+	}
+	return strings.Join(subPaths, "/") + suffix, nil
+}
+
+// replaceBraces 替换段内所有 {name} 形式的参数，没有闭合的括号原样保留
+func replaceBraces(s string, resolve func(key string) string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	var buf strings.Builder
+	for {
+		start := strings.IndexByte(s, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		buf.WriteString(s[:start])
+		buf.WriteString(resolve(s[start+1 : end]))
+		s = s[end+1:]
+	}
+	buf.WriteString(s)
+	return buf.String()
+}
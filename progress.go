@@ -0,0 +1,111 @@
+package restgo
+
+import (
+	"io"
+	"time"
+)
+
+// DefaultProgressInterval 进度回调的默认最小间隔
+const DefaultProgressInterval = 200 * time.Millisecond
+
+// ProgressFunc 传输进度回调，transferred 为已传输的字节数，total 为 -1 时表示总长度未知
+type ProgressFunc func(transferred, total int64)
+
+// UploadProgress 上传进度回调，按 ProgressInterval 节流，传输完成时一定会回调一次
+func (builder *Builder) UploadProgress(fn ProgressFunc) *Builder {
+	builder.uploadProgress = fn
+	return builder
+}
+
+// DownloadProgress 下载进度回调，按 ProgressInterval 节流，传输完成时一定会回调一次，
+// 流式请求时为已读取的响应体字节数
+func (builder *Builder) DownloadProgress(fn ProgressFunc) *Builder {
+	builder.downloadProgress = fn
+	return builder
+}
+
+// ProgressInterval 进度回调的最小间隔，默认为 DefaultProgressInterval
+func (builder *Builder) ProgressInterval(interval time.Duration) *Builder {
+	builder.progressInterval = interval
+	return builder
+}
+
+func (builder *Builder) requestProgressInterval() time.Duration {
+	if builder.progressInterval > 0 {
+		return builder.progressInterval
+	}
+	return DefaultProgressInterval
+}
+
+// uploadProgressBody 包装请求体统计上传进度，重新生成的请求体从0开始统计
+func (builder *Builder) uploadProgressBody(body io.Reader) io.Reader {
+	rb, ok := body.(*requestBody)
+	if builder.uploadProgress == nil || !ok || rb.length == 0 {
+		return body
+	}
+	fn, interval := builder.uploadProgress, builder.requestProgressInterval()
+	wrapped := &requestBody{
+		reader: newProgressReader(rb.reader, rb.length, fn, interval),
+		closer: rb.closer,
+		length: rb.length,
+	}
+	if rb.getBody != nil {
+		wrapped.getBody = func() (io.ReadCloser, error) {
+			reader, err := rb.getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newProgressReader(reader, rb.length, fn, interval), nil
+		}
+	}
+	return wrapped
+}
+
+// progressReader 统计读取的字节数并按间隔回调
+type progressReader struct {
+	reader      io.Reader
+	total       int64
+	fn          ProgressFunc
+	interval    time.Duration
+	transferred int64
+	last        time.Time
+	finished    bool
+}
+
+func newProgressReader(reader io.Reader, total int64, fn ProgressFunc, interval time.Duration) *progressReader {
+	return &progressReader{
+		reader:   reader,
+		total:    total,
+		fn:       fn,
+		interval: interval,
+		last:     time.Now(),
+	}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.transferred += int64(n)
+	if r.finished {
+		return n, err
+	}
+	// 读到结尾或者已达到总长度时视为传输完成
+	if err == io.EOF || (r.total >= 0 && r.transferred >= r.total) {
+		r.finished = true
+		r.fn(r.transferred, r.total)
+		return n, err
+	}
+	if n > 0 {
+		if now := time.Now(); now.Sub(r.last) >= r.interval {
+			r.last = now
+			r.fn(r.transferred, r.total)
+		}
+	}
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	if closer, ok := r.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package restgo
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArrayStyle 同一个查询参数有多个值时的编码方式
+type ArrayStyle int
+
+const (
+	// ArrayRepeat ids=1&ids=2
+	ArrayRepeat ArrayStyle = iota
+	// ArrayComma ids=1,2
+	ArrayComma
+	// ArrayBrackets ids[]=1&ids[]=2
+	ArrayBrackets
+)
+
+// arrayDefault QueryStruct 中没有指定编码方式的切片、数组字段，使用 QueryArrayStyle 设置的编码方式
+const arrayDefault ArrayStyle = -1
+
+// AddQuery 追加查询参数，同名参数保留已有的值
+func (builder *Builder) AddQuery(key string, values ...string) *Builder {
+	if builder.queryVal == nil {
+		builder.queryVal = make(url.Values)
+	}
+	builder.queryVal[key] = append(builder.queryVal[key], values...)
+	return builder
+}
+
+// SetQuery 设置查询参数，覆盖同名参数已有的值
+func (builder *Builder) SetQuery(key string, values ...string) *Builder {
+	if builder.queryVal == nil {
+		builder.queryVal = make(url.Values)
+	}
+	builder.queryVal[key] = append([]string(nil), values...)
+	return builder
+}
+
+// QueryValues 批量设置查询参数，覆盖同名参数已有的值
+func (builder *Builder) QueryValues(values url.Values) *Builder {
+	for k, v := range values {
+		builder.SetQuery(k, v...)
+	}
+	return builder
+}
+
+// QueryStruct 通过结构体设置查询参数，字段通过 query 标签指定参数名以及选项，例如：
+//
+//	Page int      `query:"page,omitempty"`
+//	IDs  []string `query:"ids,comma"`
+//
+// 支持的选项：omitempty 零值时忽略；comma、brackets、repeat 指定该字段的数组编码方式，只有一个值时同样生效
+// 结构体在发送请求时才转换为查询参数，同名参数以 Query、AddQuery、SetQuery 等方法设置的值为准
+func (builder *Builder) QueryStruct(v interface{}) *Builder {
+	builder.queryStructs = append(builder.queryStructs, v)
+	return builder
+}
+
+// QueryArrayStyle 同一个查询参数有多个值时的默认编码方式，默认为 ArrayRepeat
+func (builder *Builder) QueryArrayStyle(style ArrayStyle) *Builder {
+	builder.queryStyle = style
+	return builder
+}
+
+// appendQuery 将查询参数合并到 rawURL 中，Client 默认参数优先级最低，其次为 rawURL 已有的参数、QueryStruct 设置的参数，
+// 最后为当前请求直接设置的参数
+func (builder *Builder) appendQuery(rawURL string) (string, error) {
+	var fragment string
+	if idx := strings.IndexByte(rawURL, '#'); idx >= 0 {
+		rawURL, fragment = rawURL[:idx], rawURL[idx:]
+	}
+	var rawQuery string
+	if idx := strings.IndexByte(rawURL, '?'); idx >= 0 {
+		rawURL, rawQuery = rawURL[:idx], rawURL[idx+1:]
+	}
+
+	urlValues, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	values := make(url.Values)
+	if builder.client != nil {
+		for k, v := range builder.client.queryVal {
+			values[k] = v
+		}
+	}
+	for k, v := range urlValues {
+		values[k] = v
+	}
+	styles := make(map[string]ArrayStyle)
+	for _, queryStruct := range builder.queryStructs {
+		structValues, structStyles, err := encodeQueryStruct(queryStruct)
+		if err != nil {
+			return "", err
+		}
+		for k, v := range structValues {
+			values[k] = v
+		}
+		for k, style := range structStyles {
+			styles[k] = style
+		}
+	}
+	for k, v := range builder.queryVal {
+		values[k] = v
+	}
+	if query := builder.encodeQuery(values, styles); query != "" {
+		rawURL = rawURL + "?" + query
+	}
+	return rawURL + fragment, nil
+}
+
+// encodeQuery 按参数名排序编码，保证相同参数生成的URL一致
+func (builder *Builder) encodeQuery(values url.Values, styles map[string]ArrayStyle) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	write := func(key, val string) {
+		if buf.Len() > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(url.QueryEscape(key))
+		buf.WriteByte('=')
+		buf.WriteString(val)
+	}
+	for _, k := range keys {
+		vs := values[k]
+		style, list := styles[k]
+		if !list || style == arrayDefault {
+			style = builder.queryStyle
+		}
+		// 只有一个值时，只有 QueryStruct 中的切片、数组字段按数组编码，保证与多个值时的格式一致
+		if len(vs) <= 1 && !list {
+			for _, v := range vs {
+				write(k, url.QueryEscape(v))
+			}
+			continue
+		}
+		switch style {
+		case ArrayComma:
+			escaped := make([]string, 0, len(vs))
+			for _, v := range vs {
+				escaped = append(escaped, url.QueryEscape(v))
+			}
+			write(k, strings.Join(escaped, ","))
+		case ArrayBrackets:
+			for _, v := range vs {
+				write(k+"[]", url.QueryEscape(v))
+			}
+		default:
+			for _, v := range vs {
+				write(k, url.QueryEscape(v))
+			}
+		}
+	}
+	return buf.String()
+}
+
+// encodeQueryStruct 将结构体或 map 转换为查询参数
+func encodeQueryStruct(v interface{}) (url.Values, map[string]ArrayStyle, error) {
+	values := make(url.Values)
+	styles := make(map[string]ArrayStyle)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, styles, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		if err := encodeQueryFields(rv, values, styles); err != nil {
+			return nil, nil, err
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, nil, fmt.Errorf("query map key must be string, got %s", rv.Type().Key())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			vs, list, err := queryStrings(iter.Value())
+			if err != nil {
+				return nil, nil, err
+			}
+			values[iter.Key().String()] = vs
+			if list {
+				styles[iter.Key().String()] = arrayDefault
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("query struct must be struct or map, got %s", rv.Type())
+	}
+	return values, styles, nil
+}
+
+func encodeQueryFields(rv reflect.Value, values url.Values, styles map[string]ArrayStyle) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("query")
+		if tag == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+
+		// 匿名嵌入的结构体展开处理
+		if field.Anonymous && tag == "" {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := encodeQueryFields(fv, values, styles); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if idx := strings.IndexByte(tag, ','); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		if name == "" {
+			name = field.Name
+		}
+		omitEmpty := false
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				omitEmpty = true
+			case "comma":
+				styles[name] = ArrayComma
+			case "brackets":
+				styles[name] = ArrayBrackets
+			case "repeat":
+				styles[name] = ArrayRepeat
+			}
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		vs, list, err := queryStrings(fv)
+		if err != nil {
+			return fmt.Errorf("query field %s: %w", field.Name, err)
+		}
+		if vs == nil {
+			continue
+		}
+		values[name] = vs
+		if _, ok := styles[name]; list && !ok {
+			styles[name] = arrayDefault
+		}
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// queryStrings 将字段值转换为字符串，切片和数组会转换为多个值，list 表示是否为切片或数组，nil 指针返回 nil
+func queryStrings(fv reflect.Value) (vs []string, list bool, err error) {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil, false, nil
+		}
+		fv = fv.Elem()
+	}
+	if isQueryList(fv) {
+		vs = make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			s, err := formatParam(fv.Index(i))
+			if err != nil {
+				return nil, true, err
+			}
+			vs = append(vs, s)
+		}
+		return vs, true, nil
+	}
+	s, err := formatParam(fv)
+	if err != nil {
+		return nil, false, err
+	}
+	return []string{s}, false, nil
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// isQueryList 切片、数组作为多个值处理，[]byte 以及实现了文本序列化的类型（如 UUID）除外
+func isQueryList(fv reflect.Value) bool {
+	if fv.Type().Implements(textMarshalerType) || fv.Type().Implements(stringerType) {
+		return false
+	}
+	switch fv.Kind() {
+	case reflect.Array:
+		return true
+	case reflect.Slice:
+		return fv.Type().Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// formatParam 将参数值格式化为字符串，查询参数与路径参数共用
+func formatParam(fv reflect.Value) (string, error) {
+	if !fv.IsValid() {
+		return "", nil
+	}
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return "", nil
+		}
+		fv = fv.Elem()
+	}
+	if fv.Type() == timeType {
+		return fv.Interface().(time.Time).Format(time.RFC3339), nil
+	}
+	if fv.CanInterface() {
+		switch val := fv.Interface().(type) {
+		case encoding.TextMarshaler:
+			text, err := val.MarshalText()
+			return string(text), err
+		case fmt.Stringer:
+			return val.String(), nil
+		}
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, fv.Type().Bits()), nil
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(fv.Bytes()), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported query value type %s", fv.Type())
+}
@@ -0,0 +1,153 @@
+package restgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultReconnectDelay    = 3 * time.Second
+	defaultReconnectMaxDelay = 30 * time.Second
+)
+
+// ReconnectPolicy SSE 断线重连策略
+type ReconnectPolicy struct {
+	// MaxAttempts 连续重连的最大次数，0表示不限制，收到新的事件后重新计数
+	MaxAttempts int
+	// Delay 服务端没有通过 retry 字段指定重连间隔时使用的间隔，默认3秒
+	Delay time.Duration
+	// MaxDelay 连续重连失败时间隔按指数增长，该值为间隔上限，默认30秒
+	MaxDelay time.Duration
+	// OnReconnect 每次重连前回调，attempt 从1开始，err 为导致断开的错误，服务端正常关闭连接时为 nil
+	OnReconnect func(attempt int, lastEventID string, err error)
+}
+
+// StreamReconnect 开启 SSE 断线重连，连接断开后按策略重新发起请求，并通过 Last-Event-ID 请求头携带最近收到的事件ID
+// 收到结束标记、回调返回错误、context 结束或服务端返回非 5xx/408/429 的状态码时不再重连
+func (builder *Builder) StreamReconnect(policy ReconnectPolicy) *Builder {
+	if policy.Delay <= 0 {
+		policy.Delay = defaultReconnectDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultReconnectMaxDelay
+	}
+	builder.reconnect = &policy
+	return builder
+}
+
+func (builder *Builder) doStreamWithReconnect(ctx context.Context, url string, method string, body io.Reader,
+	contentType string, headers map[string]string, callback StreamCallback) error {
+	policy := builder.reconnect
+	streamRestGo := builder.requestStreamRestGo()
+	terminatorCallback := builder.terminatorCallback(callback)
+
+	var lastEventID string
+	var serverRetry time.Duration
+	attempt := 0
+	for {
+		reqHeaders := headers
+		if lastEventID != "" {
+			reqHeaders = mergeSMap(headers, map[string]string{"Last-Event-ID": lastEventID})
+		}
+
+		if attempt > 0 {
+			// 重连时重新生成请求体，请求体无法重新生成时不再重连
+			var err error
+			if body, err = rewindBody(body); err != nil {
+				return err
+			}
+		}
+
+		var callbackErr error
+		received := false
+		err := streamRestGo.DoStream(ctx, url, method, body, contentType, reqHeaders, func(resp StreamResponse, event Event) error {
+			received = true
+			lastEventID = event.ID
+			if event.Retry > 0 {
+				serverRetry = event.Retry
+			}
+			if err := terminatorCallback(resp, event); err != nil {
+				callbackErr = err
+				return err
+			}
+			return nil
+		})
+		if callbackErr != nil {
+			return callbackErr
+		}
+		if !shouldReconnect(ctx, err) {
+			return err
+		}
+
+		if received {
+			attempt = 0
+		}
+		attempt++
+		if policy.MaxAttempts > 0 && attempt > policy.MaxAttempts {
+			return err
+		}
+
+		delay := policy.Delay
+		if serverRetry > 0 {
+			delay = serverRetry
+		}
+		delay = backoffDelay(delay, policy.MaxDelay, attempt)
+		if policy.OnReconnect != nil {
+			policy.OnReconnect(attempt, lastEventID, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rewindBody 重新生成请求体用于再次发送
+func rewindBody(body io.Reader) (io.Reader, error) {
+	rb, ok := body.(*requestBody)
+	if !ok || rb.getBody == nil {
+		return nil, errors.New("request body can not be resent")
+	}
+	if rb.length == 0 {
+		return rb, nil
+	}
+	reader, err := rb.getBody()
+	if err != nil {
+		return nil, err
+	}
+	return &requestBody{reader: reader, closer: reader, length: rb.length, getBody: rb.getBody}, nil
+}
+
+// shouldReconnect 连接异常断开、服务端关闭连接以及服务端临时不可用时重连
+func shouldReconnect(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// backoffDelay 第 attempt 次重连的间隔，按指数增长且不超过 maxDelay
+func backoffDelay(delay, maxDelay time.Duration, attempt int) time.Duration {
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,185 @@
+package restgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DefaultRemoteFileMaxSize File 传入下载链接时默认允许的最大文件大小
+const DefaultRemoteFileMaxSize = 32 << 20
+
+// ErrRemoteFileTooLarge 下载链接对应的文件超过 RemoteFilePolicy.MaxSize
+var ErrRemoteFileTooLarge = errors.New("restgo: remote file too large")
+
+// RemoteFilePolicy File、FormFiles 传入下载链接时的限制
+type RemoteFilePolicy struct {
+	// MaxSize 文件最大字节数，默认为 DefaultRemoteFileMaxSize，小于0时不限制
+	MaxSize int64
+	// AllowedSchemes 允许的协议，默认为 http、https
+	AllowedSchemes []string
+	// AllowedHosts 允许的域名，支持 *.example.com 形式的通配，为空时不限制
+	AllowedHosts []string
+}
+
+// RemoteFile 设置下载链接的限制，下载链接通过当前请求的 RestGo 以及 context 获取，边下载边写入表单，
+// 受当前请求的超时控制，没有设置超时时使用 DefaultTimeout，为避免泄露鉴权信息，不经过中间件，也不携带当前请求的请求头
+func (builder *Builder) RemoteFile(policy RemoteFilePolicy) *Builder {
+	builder.remoteFilePolicy = &policy
+	return builder
+}
+
+func (builder *Builder) requestRemoteFilePolicy() RemoteFilePolicy {
+	var policy RemoteFilePolicy
+	if builder.remoteFilePolicy != nil {
+		policy = *builder.remoteFilePolicy
+	}
+	if policy.MaxSize == 0 {
+		policy.MaxSize = DefaultRemoteFileMaxSize
+	}
+	if len(policy.AllowedSchemes) == 0 {
+		policy.AllowedSchemes = []string{"http", "https"}
+	}
+	return policy
+}
+
+// isRemoteFile 带有协议的路径作为下载链接处理
+func isRemoteFile(filePath string) bool {
+	return strings.Contains(filePath, "://")
+}
+
+// check 校验下载链接的协议以及域名
+func (p RemoteFilePolicy) check(fileURL *url.URL) error {
+	if !containsFold(p.AllowedSchemes, fileURL.Scheme) {
+		return fmt.Errorf("restgo: remote file scheme [%s] not allowed", fileURL.Scheme)
+	}
+	if len(p.AllowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(fileURL.Hostname())
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("restgo: remote file host [%s] not allowed", fileURL.Hostname())
+}
+
+func containsFold(items []string, s string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteFormDataFile 下载链接在写入表单时才发起请求，文件名、Content-Type 需要根据响应确定，长度未知
+func (builder *Builder) remoteFormDataFile(ctx context.Context, formFile FormFile) (*formDataFile, error) {
+	fileURL, err := url.Parse(formFile.Path)
+	if err != nil {
+		return nil, err
+	}
+	policy := builder.requestRemoteFilePolicy()
+	if err = policy.check(fileURL); err != nil {
+		return nil, err
+	}
+	restGo := builder.restGo
+
+	return &formDataFile{
+		size:       -1,
+		rewindable: true,
+		writePart: func(writer *multipart.Writer) error {
+			output := &outputTarget{
+				openWriter: func(header func(key string) string) (io.Writer, error) {
+					size, err := strconv.ParseInt(header("Content-Length"), 10, 64)
+					if err == nil && policy.MaxSize >= 0 && size > policy.MaxSize {
+						return nil, ErrRemoteFileTooLarge
+					}
+					fileName := formFile.FileName
+					if fileName == "" {
+						fileName = remoteFileName(fileURL, header("Content-Disposition"))
+					}
+					contentType := formFile.ContentType
+					if contentType == "" {
+						contentType = header("Content-Type")
+					}
+					part, err := writer.CreatePart(formFilePartHeader(formFile, fileName, contentType))
+					if err != nil {
+						return nil, err
+					}
+					if policy.MaxSize < 0 {
+						return part, nil
+					}
+					return &limitedWriter{writer: part, remaining: policy.MaxSize}, nil
+				},
+			}
+			// 重定向后的地址同样需要校验，避免通过允许的域名跳转到内网地址
+			cfg := &requestConfig{output: output, checkRedirect: func(req *http.Request) error {
+				return policy.check(req.URL)
+			}}
+			// 没有设置超时时使用 DefaultTimeout，避免下载链接无响应时请求一直阻塞
+			fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+			if _, ok := ctx.Deadline(); !ok {
+				fetchCtx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+			}
+			defer cancel()
+			rsp, err := restGo.Do(withRequestConfig(fetchCtx, cfg), fileURL.String(), string(GET), newBytesBody(nil), "", nil)
+			if err != nil {
+				return err
+			}
+			// RestGo 的实现没有使用 checkRedirect 时，校验最终的地址
+			if finalURL := rsp.URL(); finalURL != nil {
+				if err = policy.check(finalURL); err != nil {
+					return err
+				}
+			}
+			if !output.accept(rsp.StatusCode()) {
+				return responseError(rsp, string(GET), fileURL.String())
+			}
+			// RestGo 的实现没有直接写入目标时，使用已读取的响应体写入
+			if !output.saved {
+				return output.save(rsp.StatusCode(), rsp.Header, bytes.NewReader(rsp.Body()))
+			}
+			return nil
+		},
+	}, nil
+}
+
+// remoteFileName 优先使用 Content-Disposition 中的文件名，其次为链接路径中的最后一段
+func remoteFileName(fileURL *url.URL, contentDisposition string) string {
+	if _, params, err := mime.ParseMediaType(contentDisposition); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], "\\", "/")); params["filename"] != "" && name != "/" && name != "." {
+			return name
+		}
+	}
+	if name := path.Base(fileURL.Path); name != "/" && name != "." {
+		return name
+	}
+	return "file"
+}
+
+// limitedWriter 写入超过 remaining 时返回 ErrRemoteFileTooLarge
+type limitedWriter struct {
+	writer    io.Writer
+	remaining int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.remaining {
+		return 0, ErrRemoteFileTooLarge
+	}
+	n, err := w.writer.Write(p)
+	w.remaining -= int64(n)
+	return n, err
+}
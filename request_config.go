@@ -0,0 +1,78 @@
+package restgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type requestConfigKey struct{}
+
+// requestConfig 单次请求的传输层配置，与 httptrace 类似通过 context 传递给 RestGo/StreamRestGo 的实现
+type requestConfig struct {
+	streamIdleTimeout time.Duration
+	streamDecoder     StreamDecoderFactory
+	downloadProgress  ProgressFunc
+	progressInterval  time.Duration
+	// output 不为空时成功的响应体直接写入目标，不读入内存
+	output *outputTarget
+	// rawResponse 不读取响应体，由调用方按需读取
+	rawResponse bool
+	// maxBodySize 读取响应体到内存时允许的最大长度，小于等于0时不限制
+	maxBodySize int64
+	// trace 统计请求各阶段耗时
+	trace bool
+	// keepCompressed 不解压响应体
+	keepCompressed bool
+	// charset 响应体字符集的处理方式
+	charset charsetOptions
+	// checkRedirect 不为空时每次重定向前校验目标地址，返回错误时不再跟随
+	checkRedirect func(req *http.Request) error
+	// onStreamResponse 流式请求收到状态码为200的响应头后、读取事件前回调，重连时每次连接成功都会回调
+	onStreamResponse func(resp StreamResponse)
+}
+
+func withRequestConfig(ctx context.Context, cfg *requestConfig) context.Context {
+	return context.WithValue(ctx, requestConfigKey{}, cfg)
+}
+
+// wrapResponseBody 按配置包装响应体，统计下载进度，下载进度按压缩后的长度统计，之后再解压
+func (cfg *requestConfig) wrapResponseBody(rsp *http.Response) {
+	if cfg.downloadProgress != nil {
+		rsp.Body = newProgressReader(rsp.Body, rsp.ContentLength, cfg.downloadProgress, cfg.progressInterval)
+	}
+	if !cfg.keepCompressed {
+		decompressResponse(rsp)
+	}
+}
+
+// httpClient 需要校验重定向时基于 cli 生成新的 http.Client，之后仍然执行 cli 原有的重定向策略
+func (cfg *requestConfig) httpClient(cli *http.Client) *http.Client {
+	if cfg.checkRedirect == nil {
+		return cli
+	}
+	checked := *cli
+	checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := cfg.checkRedirect(req); err != nil {
+			return err
+		}
+		if cli.CheckRedirect != nil {
+			return cli.CheckRedirect(req, via)
+		}
+		// 与 http.Client 默认的策略一致
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &checked
+}
+
+// requestConfigFrom 获取请求配置，未设置时返回零值配置
+func requestConfigFrom(ctx context.Context) *requestConfig {
+	if cfg, ok := ctx.Value(requestConfigKey{}).(*requestConfig); ok && cfg != nil {
+		return cfg
+	}
+	return &requestConfig{}
+}
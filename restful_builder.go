@@ -3,15 +3,11 @@ package restgo
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
-	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -19,12 +15,13 @@ import (
 )
 
 // 提供"傻瓜式"HTTP客户端工具,用户只需要通过"点点点"的编程风格即可构造一个http请求
-// 目前支持 GET/POST 请求
+// 支持 GET/POST/PUT/PATCH/DELETE/HEAD/OPTIONS 等请求
 // 支持路径参数以及URL拼接形式
 // POST 请求支持:
 // - multipart/form-data
 // - application/x-www-form-urlencoded
 // - application/json
+// - application/xml、text/xml
 // - binary/octet-stream
 
 type HttpMethod string
@@ -38,52 +35,89 @@ type AnyFunc func() interface{}
 type ConditionFunc func() (string, string)
 
 const (
-	tmpFile = "tmp/resource_%s.%s"
-
-	GET    = HttpMethod("GET")
-	POST   = HttpMethod("POST")
-	PUT    = HttpMethod("PUT")
-	DELETE = HttpMethod("DELETE")
+	GET     = HttpMethod("GET")
+	POST    = HttpMethod("POST")
+	PUT     = HttpMethod("PUT")
+	PATCH   = HttpMethod("PATCH")
+	DELETE  = HttpMethod("DELETE")
+	HEAD    = HttpMethod("HEAD")
+	OPTIONS = HttpMethod("OPTIONS")
+	CONNECT = HttpMethod("CONNECT")
+	TRACE   = HttpMethod("TRACE")
 
 	ApplicationJson = ContentType("application/json")
+	ApplicationXml  = ContentType("application/xml")
+	// MergePatchJson JSON Merge Patch，见 RFC 7396
+	MergePatchJson = ContentType("application/merge-patch+json")
+	// JsonPatchJson JSON Patch，见 RFC 6902
+	JsonPatchJson   = ContentType("application/json-patch+json")
+	TextXml         = ContentType("text/xml")
 	FormData        = ContentType("multipart/form-data")
 	FormDataEncoded = ContentType("application/x-www-form-urlencoded")
 	OctetStream     = ContentType("binary/octet-stream")
 )
 
+// DefaultStreamTerminator 流式请求默认的结束标记
+const DefaultStreamTerminator = "[DONE]"
+
+// ErrStopStream 在流式回调中返回该错误可以提前正常结束，StreamSend 等方法返回 nil
+var ErrStopStream = errors.New("restgo: stop stream")
+
 var ConsolePrint = func(curl string) {
 	fmt.Println(curl)
 }
 
 type Builder struct {
-	body             interface{}
-	contentType      ContentType
-	headers          map[string]string
-	fileKey          string
-	filePath         string
-	queryVal         map[string]string
-	pathVal          map[string]string
-	curlConsumerFunc func(string)
-	baseURL          string
-	bodyPayload      []byte
-	rsp              interface{}
-	formFileInfo     *formFileInfo
-	restGo           RestGo
-	forTest          bool
-	streamRestGo     StreamRestGo
-}
-
-type formFileInfo struct {
-	Reader   io.Reader
-	FileKey  string
-	FileName string
+	body              interface{}
+	contentType       ContentType
+	headers           map[string]string
+	queryVal          url.Values
+	queryStructs      []interface{}
+	queryStyle        ArrayStyle
+	pathVal           map[string]pathParam
+	curlConsumerFunc  func(string)
+	baseURL           string
+	bodyPayload       []byte
+	rsp               interface{}
+	formFiles         []FormFile
+	bodyReader        *readerSource
+	restGo            RestGo
+	forTest           bool
+	streamRestGo      StreamRestGo
+	middlewares       []Middleware
+	streamMiddlewares []StreamMiddleware
+	client            *Client
+	timeout           time.Duration
+	streamIdleTimeout time.Duration
+	expectSuccess     bool
+	errRsp            interface{}
+	streamTerminator  string
+	reconnect         *ReconnectPolicy
+	streamDecoder     StreamDecoderFactory
+	codec             Codec
+	uploadProgress    ProgressFunc
+	downloadProgress  ProgressFunc
+	progressInterval  time.Duration
+	outputPath        string
+	outputWriter      io.Writer
+	expectSHA256      string
+	resumeDownload    bool
+	remoteFilePolicy  *RemoteFilePolicy
+	rawResponse       bool
+	maxBodySize       int64
+	trace             bool
+	keepCompressed    bool
+	requestEncoding   string
+	rawCharset        bool
+	sniffCharset      bool
 }
 
 func NewRestGoBuilder() *Builder {
 	return &Builder{
-		contentType:  "application/json",
-		restGo:       defaultRestGoInstance,
-		streamRestGo: defaultStreamRestGoInstance,
+		contentType:      "application/json",
+		restGo:           defaultRestGoInstance,
+		streamRestGo:     defaultStreamRestGoInstance,
+		streamTerminator: DefaultStreamTerminator,
 	}
 }
 
@@ -113,13 +147,17 @@ func (builder *Builder) HeaderFunc(hf SFunc) *Builder {
 
 // PayloadFunc 通过函数计算需要传递的payload
 func (builder *Builder) PayloadFunc(pf AnyFunc) *Builder {
-	builder.body = pf()
-	return builder
+	return builder.Payload(pf())
 }
 
-// Payload 请求载荷，根据设置的Content-Type确定最终发送形式
+// Payload 请求载荷，根据设置的Content-Type确定最终发送形式，OctetStream 时可以为 []byte 或 io.Reader
 func (builder *Builder) Payload(body interface{}) *Builder {
 	builder.body = body
+	// 记录 reader 当前的读取位置，重试时从该位置重新发送
+	builder.bodyReader = nil
+	if reader, ok := body.(io.Reader); ok {
+		builder.bodyReader = newReaderSource(reader)
+	}
 	return builder
 }
 
@@ -129,38 +167,70 @@ func (builder *Builder) BodyPayload(body []byte) *Builder {
 	return builder
 }
 
-// RspUnmarshal 对响应体进行反序列化，响应体为JSON形式
+// Codec 指定编解码器，请求载荷使用该编解码器序列化，Content-Type 同时设置为 codec.ContentType()
+// 响应体优先根据响应的 Content-Type 选择编解码器，无法识别时使用该编解码器
+func (builder *Builder) Codec(codec Codec) *Builder {
+	builder.codec = codec
+	builder.contentType = ContentType(codec.ContentType())
+	return builder
+}
+
+// RspUnmarshal 对响应体进行反序列化，根据响应的 Content-Type 选择编解码器，无法识别时按 JSON 处理
 // 如果只关心响应体序列化到结构体的结果，通过设置这个可以减少代码的编写量
 func (builder *Builder) RspUnmarshal(v interface{}) *Builder {
 	builder.rsp = v
 	return builder
 }
 
-func (builder *Builder) File(key, path string) *Builder {
-	builder.fileKey = key
-	builder.filePath = path
+// ExpectSuccess 响应状态码不是2xx时返回 *HTTPError，并且不再对响应体执行 RspUnmarshal
+func (builder *Builder) ExpectSuccess() *Builder {
+	builder.expectSuccess = true
 	return builder
 }
 
-// FileReader 另外一种上传文件的方式，手动指定reader，如果同时设置调用了 File 方法，那么 File 方法中的参数会优先
-func (builder *Builder) FileReader(key, filename string, reader io.Reader) *Builder {
-	builder.formFileInfo = &formFileInfo{
-		Reader:   reader,
-		FileKey:  key,
-		FileName: filename,
-	}
+// RawResponse 发送请求后不读取响应体，通过 Response.BodyReader 边读边处理，或者调用 Body 时再读取到内存，
+// 响应体读取完毕或者关闭前超时控制一直生效，使用 BodyReader 时必须关闭
+func (builder *Builder) RawResponse() *Builder {
+	builder.rawResponse = true
+	return builder
+}
+
+// MaxBodySize 读取响应体到内存时允许的最大长度，超过时返回 ErrBodyTooLarge，默认使用 Client 的设置，未设置时不限制
+func (builder *Builder) MaxBodySize(n int64) *Builder {
+	builder.maxBodySize = n
 	return builder
 }
 
-// Query URL拼接参数
+// ErrorUnmarshal 响应状态码不是2xx时，将响应体反序列化到 v，设置后隐含开启 ExpectSuccess
+func (builder *Builder) ErrorUnmarshal(v interface{}) *Builder {
+	builder.errRsp = v
+	builder.expectSuccess = true
+	return builder
+}
+
+// File 追加上传的文件，path 为本地文件路径或者 http 下载链接，多次调用可以上传多个文件
+func (builder *Builder) File(key, path string) *Builder {
+	return builder.FormFiles(FormFile{Key: key, Path: path})
+}
+
+// FileReader 另外一种上传文件的方式，手动指定reader，多次调用可以上传多个文件
+func (builder *Builder) FileReader(key, filename string, reader io.Reader) *Builder {
+	return builder.FormFiles(FormFile{Key: key, FileName: filename, Reader: reader})
+}
+
+// Query URL拼接参数，覆盖同名参数已有的值，参数值会自动转义
 func (builder *Builder) Query(queryVal map[string]string) *Builder {
-	builder.queryVal = queryVal
+	for k, v := range queryVal {
+		builder.SetQuery(k, v)
+	}
 	return builder
 }
 
-// PathVariable 路径参数
+// PathVariable 批量设置路径参数，参数值会通过 url.PathEscape 转义
 func (builder *Builder) PathVariable(pathVal map[string]string) *Builder {
-	builder.pathVal = pathVal
+	for k, v := range pathVal {
+		builder.PathParam(k, v)
+	}
 	return builder
 }
 
@@ -168,6 +238,34 @@ func (builder *Builder) Send(method HttpMethod, url string) (Response, error) {
 	return builder.CtxSend(context.Background(), method, url)
 }
 
+func (builder *Builder) Get(url string) (Response, error) {
+	return builder.Send(GET, url)
+}
+
+func (builder *Builder) Post(url string) (Response, error) {
+	return builder.Send(POST, url)
+}
+
+func (builder *Builder) Put(url string) (Response, error) {
+	return builder.Send(PUT, url)
+}
+
+func (builder *Builder) Patch(url string) (Response, error) {
+	return builder.Send(PATCH, url)
+}
+
+func (builder *Builder) Delete(url string) (Response, error) {
+	return builder.Send(DELETE, url)
+}
+
+func (builder *Builder) Head(url string) (Response, error) {
+	return builder.Send(HEAD, url)
+}
+
+func (builder *Builder) Options(url string) (Response, error) {
+	return builder.Send(OPTIONS, url)
+}
+
 func (builder *Builder) Curl(curlConsumer func(curl string)) *Builder {
 	builder.curlConsumerFunc = curlConsumer
 	return builder
@@ -178,249 +276,135 @@ func (builder *Builder) BaseUrl(baseURL string) *Builder {
 	return builder
 }
 
+// Timeout 单次请求的超时时间，覆盖默认的 DefaultTimeout 以及 Client 上设置的超时时间
+// 对于流式请求，该时间限制的是整个流的总时长，通常应该使用 StreamIdleTimeout
+func (builder *Builder) Timeout(d time.Duration) *Builder {
+	builder.timeout = d
+	return builder
+}
+
+// StreamTerminator 流式请求的结束标记，收到 data 与之相等的事件时正常结束，默认为 [DONE]，传入空字符串表示不检查
+func (builder *Builder) StreamTerminator(terminator string) *Builder {
+	builder.streamTerminator = terminator
+	return builder
+}
+
+// StreamDecoder 指定流式响应的解析器，不指定时根据响应的 Content-Type 选择，无法识别时按 SSE 解析
+func (builder *Builder) StreamDecoder(factory StreamDecoderFactory) *Builder {
+	builder.streamDecoder = factory
+	return builder
+}
+
+// StreamIdleTimeout 流式请求的空闲超时时间，超过该时间没有收到任何数据则断开连接并返回 ErrStreamIdleTimeout
+func (builder *Builder) StreamIdleTimeout(d time.Duration) *Builder {
+	builder.streamIdleTimeout = d
+	return builder
+}
+
 func (builder *Builder) ForTest() *Builder {
 	builder.forTest = true
 	return builder
 }
 
-func (builder *Builder) generatePayloadAndContentType() (payload *bytes.Buffer, contentType string, bodyCurl string, err error) {
+func (builder *Builder) generatePayloadAndContentType(ctx context.Context) (payload io.Reader, contentType string, bodyCurl string, err error) {
 	if len(builder.bodyPayload) > 0 {
-		return bytes.NewBuffer(builder.bodyPayload),
+		return newBytesBody(builder.bodyPayload),
 			string(builder.contentType),
-			builder.generateJsonPayloadCurl(string(builder.bodyPayload)), nil
+			builder.generateRawPayloadCurl(string(builder.bodyPayload)), nil
 	}
 
-	if builder.fileKey != "" {
+	if len(builder.formFiles) > 0 {
 		builder.contentType = FormData
 	}
 
 	switch builder.contentType {
-	case ApplicationJson:
-		return builder.generateJsonWriter()
 	case FormData:
-		return builder.generateFormDataFileWriter()
+		return builder.generateFormDataFileWriter(ctx)
 	case OctetStream:
 		return builder.generateOctetStreamWriter()
 	case FormDataEncoded:
 		return builder.generateFormDataEncodedWriter()
 	default:
-		return nil, "", "", fmt.Errorf("content-type:[%s] not support", builder.contentType)
+		codec := builder.requestCodec()
+		if codec == nil {
+			return nil, "", "", fmt.Errorf("content-type:[%s] not support", builder.contentType)
+		}
+		return builder.generateCodecWriter(codec)
 	}
 }
 
-func (builder *Builder) generateFormDataFileWriter() (*bytes.Buffer, string, string, error) {
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	var err error
-
-	defer func(writer *multipart.Writer) {
-		err := writer.Close()
-		if err != nil {
-			fmt.Printf("close file failed, fail info: %v\n", err)
-		}
-	}(writer)
-
-	if builder.filePath != "" {
-		var tmpFile string
-
-		// 处理下载链接
-		if strings.HasPrefix(builder.filePath, "http") {
-			tmpFile, err = builder.saveTmpFile()
-			if err != nil {
-				return nil, "", "", err
-			}
-		}
-		if tmpFile != "" {
-			builder.filePath = tmpFile
-			defer func(name string) {
-				err := os.Remove(name) // ignore_security_alert
-				if err != nil {
-					fmt.Printf("del tmp file [%s] failed, fail info: %v\n", tmpFile, err)
-				}
-			}(tmpFile)
-		}
-
-		file, err := os.Open(builder.filePath)
-		defer func(file *os.File) {
-			err := file.Close()
-			if err != nil {
-				fmt.Printf("close file failed, fail info: %v\n", err)
-			}
-		}(file)
-		if err != nil {
-			return nil, "", "", err
-		}
-
-		formWriter, err := writer.CreateFormFile(builder.fileKey, filepath.Base(builder.filePath))
-		if err != nil {
-			return nil, "", "", err
-		}
-		_, err = io.Copy(formWriter, file)
-		if err != nil {
-			return nil, "", "", err
-		}
-	} else if builder.formFileInfo != nil {
-		formWriter, err := writer.CreateFormFile(builder.formFileInfo.FileKey, filepath.Base(builder.formFileInfo.FileName))
-		if err != nil {
-			return nil, "", "", err
-		}
-		if _, err = io.Copy(formWriter, builder.formFileInfo.Reader); err != nil {
-			return nil, "", "", err
-		}
-	}
-	var formDataPayloadCurl string
-	if builder.body != nil {
-		var bodybytes []byte
-		bodybytes, err = json.Marshal(builder.body)
-		param := make(map[string]string)
-		err := json.Unmarshal(bodybytes, &param)
-		if err != nil {
-			return nil, "", "", err
-		}
-		formDataPayloadCurl = builder.generateFormDataPayloadCurl(param)
-		for key, val := range param {
-			_ = writer.WriteField(key, val)
-		}
-	} else {
-		formDataPayloadCurl = builder.generateFormDataPayloadCurl(nil)
+// requestCodec 请求体的编解码器，Codec 指定的编解码器与 Content-Type 一致时优先使用，
+// 之后通过 ContentType 修改了 Content-Type 时按 Content-Type 查找，避免请求体与 Content-Type 不一致
+func (builder *Builder) requestCodec() Codec {
+	if builder.codec != nil && sameMediaType(builder.codec.ContentType(), string(builder.contentType)) {
+		return builder.codec
 	}
-
-	return body, writer.FormDataContentType(), formDataPayloadCurl, nil
+	return lookupCodec(string(builder.contentType))
 }
 
-func (builder *Builder) generateJsonWriter() (payload *bytes.Buffer, contentType string, bodyCurl string, err error) {
+func (builder *Builder) generateCodecWriter(codec Codec) (payload io.Reader, contentType string, bodyCurl string, err error) {
 	if builder.body == nil {
 		return nil, "", "", nil
 	}
-	jsonBytes, err := json.Marshal(builder.body)
+	bodyBytes, err := codec.Marshal(builder.body)
 	if err != nil {
 		return nil, "", "", err
 	}
-	payload = bytes.NewBuffer(jsonBytes)
+	payload = newBytesBody(bodyBytes)
 	contentType = string(builder.contentType)
-	bodyCurl = builder.generateJsonPayloadCurl(string(jsonBytes))
+	bodyCurl = builder.generateRawPayloadCurl(string(bodyBytes))
 	return
 }
 
-func (builder *Builder) saveTmpFile() (string, error) {
-	rsp, err := http.Get(builder.filePath) // ignore_security_alert
-	if err != nil {
-		return "", err
-	}
-	defer rsp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return "", err
-	}
-	wrapper := &IResponse{
-		respBody: bodyBytes,
-		response: rsp,
-	}
-	contentType := http.DetectContentType(wrapper.Body())
-	suffix := strings.Split(contentType, "/")[1]
-
-	// 生成临时文件前缀，防止并发上传文件名称冲突问题
-	filePrefix := fmt.Sprintf("%d_%d", time.Now().UnixNano(), rand.Int63())
-	tmpFile := fmt.Sprintf(tmpFile, filePrefix, suffix)
-	err = writeFile(wrapper.Body(), tmpFile)
-	if err != nil {
-		return "", err
-	}
-	return tmpFile, nil
-}
-
-func (builder *Builder) generateOctetStreamWriter() (*bytes.Buffer, string, string, error) {
-	if bytePayload, ok := builder.body.([]byte); ok {
-		payload := bytes.NewBuffer(bytePayload)
-		contentType := string(builder.contentType)
-		return payload, contentType, "raw", nil
-	}
-	return nil, "", "", fmt.Errorf("body convert bytes failed")
-}
-
-func (builder *Builder) generateQuery() string {
-	queryVal := builder.queryVal
-	if queryVal == nil {
-		return ""
-	}
-	var kvList []string
-	for k, v := range queryVal {
-		kvList = append(kvList, fmt.Sprintf("%s=%s", k, v))
-	}
-	return strings.Join(kvList, "&")
-}
-
-func (builder *Builder) setPathVariable(url string) (string, error) {
-	if builder.pathVal == nil {
-		return url, nil
-	}
-	subPaths := strings.Split(url, "/")
-	for idx, subPath := range subPaths {
-		if strings.HasPrefix(subPath, ":") {
-			key := subPath[1:]
-			val, ok := builder.pathVal[key]
-			if !ok {
-				return "", fmt.Errorf("path val [%s] not set", key)
-			}
-			subPaths[idx] = val
+// generateOctetStreamWriter 载荷为 []byte 或 io.Reader，io.Reader 会直接作为请求体边读边发送
+func (builder *Builder) generateOctetStreamWriter() (io.Reader, string, string, error) {
+	contentType := string(builder.contentType)
+	switch payload := builder.body.(type) {
+	case []byte:
+		return newBytesBody(payload), contentType, "raw", nil
+	case io.Reader:
+		source := builder.bodyReader
+		if source == nil {
+			source = newReaderSource(payload)
+		}
+		if err := source.reset(); err != nil {
+			return nil, "", "", err
 		}
+		return source.newBody(), contentType, "raw", nil
 	}
-	return strings.Join(subPaths, "/"), nil
+	return nil, "", "", fmt.Errorf("body convert bytes failed")
 }
 
-func (builder *Builder) generateFormDataEncodedWriter() (*bytes.Buffer, string, string, error) {
+func (builder *Builder) generateFormDataEncodedWriter() (io.Reader, string, string, error) {
 	if builder.body == nil {
 		return nil, "", "", nil
 	}
-	data := url.Values{}
-	bodyBytes, err := json.Marshal(builder.body)
-	param := make(map[string]string)
-	err = json.Unmarshal(bodyBytes, &param)
+	keys, fields, err := formFields(builder.body)
 	if err != nil {
 		return nil, "", "", err
 	}
-	for key, val := range param {
-		data.Set(key, val)
-	}
-	var b bytes.Buffer
-	b.Write([]byte(data.Encode()))
-	return &b, string(FormDataEncoded), builder.generateFormDataEncodedPayloadCurl(param), nil
+	return newBytesBody([]byte(fields.Encode())), string(FormDataEncoded), builder.generateFormDataEncodedPayloadCurl(keys, fields), nil
 }
 
-func (builder *Builder) generateFormDataEncodedPayloadCurl(param map[string]string) string {
+func (builder *Builder) generateFormDataEncodedPayloadCurl(keys []string, fields url.Values) string {
 	if builder.curlConsumerFunc == nil {
 		return ""
 	}
 	var buf strings.Builder
-	for k, v := range param {
-		buf.WriteString(fmt.Sprintf("--data-urlencode '%s=%s' \\\n", k, v))
+	for _, k := range keys {
+		for _, v := range fields[k] {
+			buf.WriteString(fmt.Sprintf("--data-urlencode '%s=%s' \\\n", shellQuoteEscape(k), shellQuoteEscape(v)))
+		}
 	}
 	return buf.String()
 }
 
-func (builder *Builder) generateJsonPayloadCurl(reqJson string) string {
+// generateRawPayloadCurl 原样输出请求体，JSON、XML 等文本格式通用
+func (builder *Builder) generateRawPayloadCurl(payload string) string {
 	if builder.curlConsumerFunc == nil {
 		return ""
 	}
-	return fmt.Sprintf("--data-raw '%s'", reqJson)
-}
-
-func (builder *Builder) generateFormDataPayloadCurl(param map[string]string) string {
-	if builder.curlConsumerFunc == nil {
-		return ""
-	}
-	var buf strings.Builder
-	for k, v := range param {
-		buf.WriteString(fmt.Sprintf("--form '%s=\"%s\"' \\\n", k, v))
-	}
-	if builder.fileKey != "" {
-		buf.WriteString(fmt.Sprintf("--form '%s=@\"%s\"' \\\n", builder.fileKey, builder.filePath))
-	} else if builder.formFileInfo != nil {
-		buf.WriteString(fmt.Sprintf("--form '%s=@\"%s\"' \\\n", builder.formFileInfo.FileKey, builder.formFileInfo.FileName))
-	}
-	return buf.String()
+	return fmt.Sprintf("--data-raw '%s'", shellQuoteEscape(payload))
 }
 
 func (builder *Builder) generateCurl(headers map[string]string, contentType, payload string, url string, method HttpMethod) string {
@@ -436,6 +420,73 @@ func (builder *Builder) generateCurl(headers map[string]string, contentType, pay
 	return buf.String()
 }
 
+// requestHeaders 合并 Client 默认请求头与当前请求设置的请求头，当前请求优先
+func (builder *Builder) requestHeaders() map[string]string {
+	if builder.client == nil || len(builder.client.headers) == 0 {
+		return builder.headers
+	}
+	return mergeSMap(builder.client.headers, builder.headers)
+}
+
+func (builder *Builder) requestBaseURL() string {
+	if builder.baseURL == "" && builder.client != nil {
+		return builder.client.baseURL
+	}
+	return builder.baseURL
+}
+
+func (builder *Builder) requestTimeout() time.Duration {
+	if builder.timeout == 0 && builder.client != nil {
+		return builder.client.timeout
+	}
+	return builder.timeout
+}
+
+func (builder *Builder) requestConfig() *requestConfig {
+	cfg := &requestConfig{
+		streamIdleTimeout: builder.streamIdleTimeout,
+		streamDecoder:     builder.streamDecoder,
+		rawResponse:       builder.rawResponse,
+		maxBodySize:       builder.maxBodySize,
+		trace:             builder.trace,
+		keepCompressed:    builder.keepCompressed,
+		charset:           charsetOptions{raw: builder.rawCharset, sniff: builder.sniffCharset},
+	}
+	if cfg.maxBodySize == 0 && builder.client != nil {
+		cfg.maxBodySize = builder.client.maxBodySize
+	}
+	if builder.downloadProgress != nil {
+		cfg.downloadProgress = builder.downloadProgress
+		cfg.progressInterval = builder.requestProgressInterval()
+	}
+	return cfg
+}
+
+func (builder *Builder) requestRestGo() RestGo {
+	restGo := chainRestGo(builder.restGo, builder.middlewares)
+	if builder.client != nil {
+		restGo = chainRestGo(restGo, builder.client.middlewares)
+	}
+	return restGo
+}
+
+func (builder *Builder) requestStreamRestGo() StreamRestGo {
+	streamRestGo := chainStreamRestGo(builder.streamRestGo, builder.streamMiddlewares)
+	if builder.client != nil {
+		streamRestGo = chainStreamRestGo(streamRestGo, builder.client.streamMiddlewares)
+	}
+	return streamRestGo
+}
+
+func (builder *Builder) retryOptions(ops []retry.Option) []retry.Option {
+	if builder.client == nil || len(builder.client.retryOptions) == 0 {
+		return ops
+	}
+	merged := make([]retry.Option, 0, len(builder.client.retryOptions)+len(ops))
+	merged = append(merged, builder.client.retryOptions...)
+	return append(merged, ops...)
+}
+
 func (builder *Builder) CustomRestGo(customRestGo RestGo) *Builder {
 	builder.restGo = customRestGo
 	return builder
@@ -446,15 +497,52 @@ func (builder *Builder) CustomStreamRestGo(customStreamRestGo StreamRestGo) *Bui
 	return builder
 }
 
+// Use 注册请求中间件，按注册顺序由外向内执行
+func (builder *Builder) Use(middlewares ...Middleware) *Builder {
+	builder.middlewares = append(builder.middlewares, middlewares...)
+	return builder
+}
+
+// UseStream 注册流式请求中间件，按注册顺序由外向内执行
+func (builder *Builder) UseStream(middlewares ...StreamMiddleware) *Builder {
+	builder.streamMiddlewares = append(builder.streamMiddlewares, middlewares...)
+	return builder
+}
+
+// StreamSend 发送流式请求，回调中只能拿到事件的 data 字段，需要完整事件时使用 StreamSendEvent
 func (builder *Builder) StreamSend(method HttpMethod, url string, callback func(resp StreamResponse, rspBody string) error) error {
 	return builder.CtxStreamSend(context.Background(), method, url, callback)
 }
 
 func (builder *Builder) CtxStreamSend(ctx context.Context, method HttpMethod, url string, callback func(resp StreamResponse, rspBody string) error) error {
+	return builder.CtxStreamSendEvent(ctx, method, url, func(resp StreamResponse, event Event) error {
+		return callback(resp, event.Data)
+	})
+}
+
+// StreamSendEvent 发送流式请求，回调中可以拿到事件的 ID、Type、Data、Retry
+func (builder *Builder) StreamSendEvent(method HttpMethod, url string, callback StreamCallback) error {
+	return builder.CtxStreamSendEvent(context.Background(), method, url, callback)
+}
+
+func (builder *Builder) CtxStreamSendEvent(ctx context.Context, method HttpMethod, url string, callback StreamCallback) error {
+	return builder.ctxStreamSendEvent(ctx, method, url, nil, callback)
+}
+
+// ctxStreamSendEvent onResponse 不为空时，收到响应头后立即回调，不等待第一条事件
+func (builder *Builder) ctxStreamSendEvent(ctx context.Context, method HttpMethod, url string,
+	onResponse func(resp StreamResponse), callback StreamCallback) error {
 	// 避免传值传的不是标准的请求方法导致请求错误
 	method = HttpMethod(strings.ToUpper(string(method)))
 
-	body, contentType, curlPayload, err := builder.generatePayloadAndContentType()
+	// 超时在生成请求体之前生效，请求体中的下载链接同样受超时控制
+	if builder.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, builder.timeout)
+		defer cancel()
+	}
+
+	body, contentType, curlPayload, err := builder.generatePayloadAndContentType(ctx)
 	if err != nil {
 		return err
 	}
@@ -464,35 +552,88 @@ func (builder *Builder) CtxStreamSend(ctx context.Context, method HttpMethod, ur
 		return err
 	}
 
-	query := builder.generateQuery()
-	if query != "" {
-		url = fmt.Sprintf("%s?%s", url, query)
+	url, err = joinURL(builder.requestBaseURL(), url)
+	if err != nil {
+		return err
 	}
-	if builder.baseURL != "" {
-		url = fmt.Sprintf("%s%s", builder.baseURL, url)
+
+	url, err = builder.appendQuery(url)
+	if err != nil {
+		return err
 	}
 
 	if body == nil {
-		body = bytes.NewBufferString("")
+		body = newBytesBody(nil)
+	}
+	plainBody := body
+	body = builder.uploadProgressBody(body)
+	body, encoding, err := builder.compressBody(body)
+	if err != nil {
+		return err
 	}
 
+	headers := builder.requestHeaders()
+	if encoding != "" {
+		headers = mergeSMap(headers, map[string]string{"Content-Encoding": encoding})
+	}
 	if builder.curlConsumerFunc != nil {
-		curl := builder.generateCurl(builder.headers, contentType, curlPayload, url, method)
-		builder.curlConsumerFunc(curl)
+		curlInput, curlPayload, err := compressedCurl(plainBody, encoding, curlPayload)
+		if err != nil {
+			return err
+		}
+		curl := builder.generateCurl(headers, contentType, curlPayload, url, method)
+		builder.curlConsumerFunc(curlInput + curl)
 	}
 
 	if builder.forTest {
 		return nil
 	}
 
-	return builder.streamRestGo.DoStream(ctx, url, string(method), body, contentType, builder.headers, callback)
+	cfg := builder.requestConfig()
+	cfg.onStreamResponse = onResponse
+	ctx = withRequestConfig(ctx, cfg)
+
+	if builder.reconnect != nil {
+		err = builder.doStreamWithReconnect(ctx, url, string(method), body, contentType, headers, callback)
+	} else {
+		err = builder.requestStreamRestGo().DoStream(ctx, url, string(method), body, contentType, headers, builder.terminatorCallback(callback))
+	}
+	if errors.Is(err, ErrStopStream) {
+		return nil
+	}
+	return err
+}
+
+// terminatorCallback 收到结束标记时停止读取
+func (builder *Builder) terminatorCallback(callback StreamCallback) StreamCallback {
+	terminator := builder.streamTerminator
+	return func(resp StreamResponse, event Event) error {
+		// 检查是否结束
+		if terminator != "" && event.Data == terminator {
+			return ErrStopStream
+		}
+		return callback(resp, event)
+	}
 }
 
 func (builder *Builder) CtxSend(ctx context.Context, method HttpMethod, url string) (Response, error) {
 	// 避免传值传的不是标准的请求方法导致请求错误
 	method = HttpMethod(strings.ToUpper(string(method)))
 
-	body, contentType, curlPayload, err := builder.generatePayloadAndContentType()
+	// 超时在生成请求体之前生效，请求体中的下载链接同样受超时控制
+	cancel := context.CancelFunc(func() {})
+	if timeout := builder.requestTimeout(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	// RawResponse 模式下响应体关闭时才结束超时控制
+	attached := false
+	defer func() {
+		if !attached {
+			cancel()
+		}
+	}()
+
+	body, contentType, curlPayload, err := builder.generatePayloadAndContentType(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -502,21 +643,45 @@ func (builder *Builder) CtxSend(ctx context.Context, method HttpMethod, url stri
 		return nil, err
 	}
 
-	query := builder.generateQuery()
-	if query != "" {
-		url = fmt.Sprintf("%s?%s", url, query)
+	url, err = joinURL(builder.requestBaseURL(), url)
+	if err != nil {
+		return nil, err
 	}
-	if builder.baseURL != "" {
-		url = fmt.Sprintf("%s%s", builder.baseURL, url)
+
+	url, err = builder.appendQuery(url)
+	if err != nil {
+		return nil, err
 	}
 
 	if body == nil {
-		body = bytes.NewBufferString("")
+		body = newBytesBody(nil)
+	}
+	plainBody := body
+	body = builder.uploadProgressBody(body)
+	body, encoding, err := builder.compressBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := builder.newOutputTarget()
+	if err != nil {
+		return nil, err
 	}
 
+	headers := builder.requestHeaders()
+	if encoding != "" {
+		headers = mergeSMap(headers, map[string]string{"Content-Encoding": encoding})
+	}
+	if output != nil && output.rangeHeader() != "" {
+		headers = mergeSMap(headers, map[string]string{"Range": output.rangeHeader()})
+	}
 	if builder.curlConsumerFunc != nil {
-		curl := builder.generateCurl(builder.headers, contentType, curlPayload, url, method)
-		builder.curlConsumerFunc(curl)
+		curlInput, curlPayload, err := compressedCurl(plainBody, encoding, curlPayload)
+		if err != nil {
+			return nil, err
+		}
+		curl := builder.generateCurl(headers, contentType, curlPayload, url, method)
+		builder.curlConsumerFunc(curlInput + curl)
 	}
 
 	if builder.forTest {
@@ -524,26 +689,68 @@ func (builder *Builder) CtxSend(ctx context.Context, method HttpMethod, url stri
 	}
 
 	var respW Response
-	respW, err = builder.restGo.Do(ctx, url, string(method), body, contentType, builder.headers)
+	cfg := builder.requestConfig()
+	cfg.output = output
+	ctx = withRequestConfig(ctx, cfg)
+	respW, err = builder.requestRestGo().Do(ctx, url, string(method), body, contentType, headers)
 	if err != nil {
 		return nil, err
 	}
+	if iResp, ok := respW.(*IResponse); ok {
+		attached = iResp.attachCancel(cancel)
+	}
 
-	if builder.rsp != nil {
-		if err = json.Unmarshal(respW.Body(), builder.rsp); err != nil {
+	if output != nil {
+		if !output.accept(respW.StatusCode()) {
+			return respW, responseError(respW, string(method), url)
+		}
+		// RestGo 的实现没有直接写入目标时，使用已读取的响应体写入
+		if !output.saved {
+			if err = output.save(respW.StatusCode(), respW.Header, bytes.NewReader(respW.Body())); err != nil {
+				return nil, err
+			}
+		}
+		return respW, nil
+	}
+
+	if builder.expectSuccess || (builder.client != nil && builder.client.expectSuccess) {
+		if !isSuccess(respW.StatusCode()) {
+			if builder.errRsp != nil {
+				// 错误响应体格式不符合预期时仍然返回 HTTPError，调用方可以通过 Body 自行处理
+				_ = builder.unmarshalResponse(respW, builder.errRsp)
+			}
+			return respW, responseError(respW, string(method), url)
+		}
+	}
+
+	// HEAD 请求以及 204 响应没有响应体
+	if builder.rsp != nil && method != HEAD && respW.StatusCode() != http.StatusNoContent {
+		if err = builder.unmarshalResponse(respW, builder.rsp); err != nil {
 			return nil, err
 		}
 	}
 	return respW, nil
 }
 
+func (builder *Builder) unmarshalResponse(respW Response, v interface{}) error {
+	body := respW.Body()
+	if iResp, ok := respW.(*IResponse); ok {
+		// RawResponse 模式下读取响应体失败时返回具体原因，非 UTF-8 字符集转换为 UTF-8
+		var err error
+		if body, err = iResp.textBody(); err != nil {
+			return err
+		}
+	}
+	return unmarshalBody(respW.Header("Content-Type"), body, v, builder.codec)
+}
+
 func (builder *Builder) SendWithRetry(method HttpMethod, url string, resF func(respW Response, err error) error, ops ...retry.Option) (Response, error) {
 	var respW Response
 	var err error
 	err = retry.Do(func() error {
 		respW, err = builder.Send(method, url)
 		return resF(respW, err)
-	}, ops...)
+	}, builder.retryOptions(ops)...)
 	return respW, err
 }
 
@@ -553,6 +760,6 @@ func (builder *Builder) CtxSendWithRetry(ctx context.Context, method HttpMethod,
 	err = retry.Do(func() error {
 		respW, err = builder.CtxSend(ctx, method, url)
 		return resF(respW, err)
-	}, ops...)
+	}, builder.retryOptions(ops)...)
 	return respW, err
 }
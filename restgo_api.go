@@ -1,24 +1,48 @@
 package restgo
 
 import (
-	"bytes"
 	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
 )
 
 type Response interface {
 	Header(key string) string
 	BodyStr() string
 	Body() []byte
+	// BodyReader 响应体的 reader，使用完毕后必须关闭
+	BodyReader() io.ReadCloser
 	BodyUnmarshal(v interface{}) error
 	Status() string
 	StatusCode() int
 	Proto() string
 	ProtoMajor() int
 	ProtoMinor() int
+	// Headers 全部响应头
+	Headers() http.Header
+	// HeaderValues 响应头的全部值，例如 Set-Cookie、Link
+	HeaderValues(key string) []string
+	// Cookies 解析 Set-Cookie 得到的 cookie
+	Cookies() []*http.Cookie
+	// Request 发起的请求，发生重定向时为最后一次请求
+	Request() *http.Request
+	// URL 最终请求的 URL，发生重定向时为重定向后的地址
+	URL() *url.URL
+	// ContentLength 响应头中的长度，未知时为 -1
+	ContentLength() int64
+	// TLS https 请求的连接信息，http 请求时为 nil
+	TLS() *tls.ConnectionState
+	// Duration 请求耗时，从发送请求到读取完响应体，RawResponse 模式下到收到响应头
+	Duration() time.Duration
+	// TraceInfo 请求各阶段耗时，需要通过 Builder.EnableTrace 开启
+	TraceInfo() TraceInfo
 }
 
 type RestGo interface {
-	Do(ctx context.Context, url string, method string, body *bytes.Buffer, contentType string, headers map[string]string) (Response, error)
+	Do(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error)
 }
 
 type StreamResponse interface {
@@ -28,8 +52,20 @@ type StreamResponse interface {
 	Proto() string
 	ProtoMajor() int
 	ProtoMinor() int
+	Headers() http.Header
+	HeaderValues(key string) []string
+	Cookies() []*http.Cookie
+	Request() *http.Request
+	URL() *url.URL
+	ContentLength() int64
+	TLS() *tls.ConnectionState
+	// Duration 从发送请求到收到响应头的耗时
+	Duration() time.Duration
 }
 
+// StreamCallback 流式响应回调，返回 ErrStopStream 可以提前正常结束，返回其他错误会中断并透传该错误
+type StreamCallback func(resp StreamResponse, event Event) error
+
 type StreamRestGo interface {
-	DoStream(ctx context.Context, url string, method string, body *bytes.Buffer, contentType string, headers map[string]string, callback func(StreamResponse, string) error) error
+	DoStream(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string, callback StreamCallback) error
 }
@@ -2,18 +2,34 @@ package restgo
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/avast/retry-go"
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 type Person struct {
@@ -110,11 +126,44 @@ func startHttpServer() (*http.Server, error) {
 	mux.HandleFunc("/user/upload_cover", uploadUserCover)
 	mux.HandleFunc("/retry/test", retryMock)
 	mux.HandleFunc("/headers", respHeaders)
+	mux.HandleFunc("/slow", slowHandler)
+	mux.HandleFunc("/error", errorHandler)
+	mux.HandleFunc("/echo", echoHandler)
+	mux.HandleFunc("/echo/header", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(strings.Join(request.Header.Values(request.URL.Query().Get("name")), ",")))
+	})
+	mux.HandleFunc("/cors", corsHandler)
+	mux.HandleFunc("/upload", uploadHandler)
+	mux.HandleFunc("/download", downloadHandler)
+	mux.HandleFunc("/cookies", cookiesHandler)
+	mux.HandleFunc("/compressed", compressedHandler)
+	mux.HandleFunc("/charset", charsetHandler)
+	mux.HandleFunc("/decompress", decompressHandler)
+	mux.HandleFunc("/redirect/to", func(writer http.ResponseWriter, request *http.Request) {
+		http.Redirect(writer, request, request.URL.Query().Get("url"), http.StatusFound)
+	})
+	mux.HandleFunc("/redirect", func(writer http.ResponseWriter, request *http.Request) {
+		http.Redirect(writer, request, "/cookies?from=redirect", http.StatusFound)
+	})
+	mux.HandleFunc("/query", rawQueryHandler)
+	mux.HandleFunc("/path/", rawPathHandler)
+	mux.HandleFunc("/sse/stall", sseStallHandler)
+	mux.HandleFunc("/sse/keepalive", sseKeepAliveHandler)
+	mux.HandleFunc("/sse/events", sseEventsHandler)
+	mux.HandleFunc("/sse/reconnect", sseReconnectHandler)
+	mux.HandleFunc("/stream/ndjson", ndjsonHandler)
+	mux.HandleFunc("/stream/array", jsonArrayHandler)
+	mux.HandleFunc("/stream/frames", lengthPrefixedHandler)
 	s := &http.Server{
 		Addr:    ":8080",
 		Handler: mux,
 	}
-	go s.ListenAndServe()
+	// 先完成监听再返回，避免用例在服务启动前发起请求
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	go s.Serve(listener)
 	return s, nil
 }
 
@@ -124,6 +173,226 @@ func respHeaders(writer http.ResponseWriter, request *http.Request) {
 	writer.WriteHeader(http.StatusOK)
 }
 
+func slowHandler(writer http.ResponseWriter, request *http.Request) {
+	delay, _ := time.ParseDuration(request.URL.Query().Get("delay"))
+	select {
+	case <-time.After(delay):
+		respOk(writer)
+	case <-request.Context().Done():
+	}
+}
+
+// echoHandler 原样返回请求体，响应的 Content-Type 与请求一致
+func echoHandler(writer http.ResponseWriter, request *http.Request) {
+	bodyBytes, err := io.ReadAll(request.Body)
+	if err != nil {
+		respErr(writer)
+		return
+	}
+	writer.Header().Set("Content-Type", request.Header.Get("Content-Type"))
+	writer.Write(bodyBytes)
+}
+
+// uploadHandler 返回请求体长度以及表单内容
+func uploadHandler(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseMultipartForm(1 << 20); err != nil {
+		respErr(writer)
+		return
+	}
+	result := map[string]interface{}{
+		"content_length": request.ContentLength,
+		"fields":         request.MultipartForm.Value,
+	}
+	files := make(map[string][]string)
+	for key, headers := range request.MultipartForm.File {
+		for _, header := range headers {
+			file, err := header.Open()
+			if err != nil {
+				respErr(writer)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			file.Close()
+			files[key] = append(files[key], strings.Join([]string{
+				header.Filename, header.Header.Get("Content-Type"), header.Header.Get("X-Part"), string(content),
+			}, ":"))
+		}
+	}
+	result["files"] = files
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(result)
+}
+
+var downloadContent = strings.Repeat("0123456789", 1000)
+
+// downloadHandler 支持 Range 请求的文件下载
+func downloadHandler(writer http.ResponseWriter, request *http.Request) {
+	if name := request.URL.Query().Get("name"); name != "" {
+		writer.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	}
+	http.ServeContent(writer, request, "download.txt", time.Time{}, strings.NewReader(downloadContent))
+}
+
+func cookiesHandler(writer http.ResponseWriter, request *http.Request) {
+	http.SetCookie(writer, &http.Cookie{Name: "session", Value: "abc"})
+	http.SetCookie(writer, &http.Cookie{Name: "theme", Value: "dark"})
+	writer.Header().Add("Link", `</page/2>; rel="next"`)
+	writer.Header().Add("Link", `</page/9>; rel="last"`)
+	writer.Write([]byte("ok"))
+}
+
+// compressedHandler 按 encoding 参数压缩响应体
+func compressedHandler(writer http.ResponseWriter, request *http.Request) {
+	encoding := request.URL.Query().Get("encoding")
+	writer.Header().Set("X-Accept-Encoding", request.Header.Get("Accept-Encoding"))
+	writer.Header().Set("Content-Encoding", encoding)
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(writer)
+	case "deflate":
+		w = zlib.NewWriter(writer)
+	case "br":
+		w = brotli.NewWriter(writer)
+	case "raw-deflate":
+		writer.Header().Set("Content-Encoding", "deflate")
+		w, _ = flate.NewWriter(writer, flate.DefaultCompression)
+	case "reverse":
+		for i := len(downloadContent) - 1; i >= 0; i-- {
+			writer.Write([]byte{downloadContent[i]})
+		}
+		return
+	}
+	w.Write([]byte(downloadContent))
+	w.Close()
+}
+
+// decompressHandler 解压 gzip 请求体后原样返回
+func decompressHandler(writer http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Content-Encoding") != "gzip" {
+		respErr(writer)
+		return
+	}
+	reader, err := gzip.NewReader(request.Body)
+	if err != nil {
+		respErr(writer)
+		return
+	}
+	body, _ := io.ReadAll(reader)
+	writer.Write(body)
+}
+
+// charsetHandler 返回 GBK、Latin-1 等非 UTF-8 编码的内容
+func charsetHandler(writer http.ResponseWriter, request *http.Request) {
+	gbk := func(s string) []byte {
+		encoded, _ := simplifiedchinese.GBK.NewEncoder().String(s)
+		return []byte(encoded)
+	}
+	switch request.URL.Query().Get("type") {
+	case "json":
+		writer.Header().Set("Content-Type", "application/json; charset=GBK")
+		writer.Write(gbk(`{"username":"张三","user_id":1}`))
+	case "latin1":
+		writer.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+		writer.Write([]byte{'c', 'a', 'f', 0xe9})
+	case "html":
+		writer.Header().Set("Content-Type", "text/html")
+		writer.Write(gbk(`<html><head><meta charset="gbk"></head><body>你好</body></html>`))
+	case "xml":
+		writer.Header().Set("Content-Type", "application/xml")
+		writer.Write(gbk(`<?xml version="1.0" encoding="GBK"?><person><username>李四</username><user_id>2</user_id></person>`))
+	}
+}
+
+func corsHandler(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodOptions {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writer.Header().Set("Access-Control-Allow-Origin", request.Header.Get("Origin"))
+	writer.Header().Set("Access-Control-Allow-Methods", request.Header.Get("Access-Control-Request-Method"))
+	writer.Header().Set("Access-Control-Allow-Headers", request.Header.Get("Access-Control-Request-Headers"))
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func rawQueryHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Write([]byte(request.URL.RawQuery))
+}
+
+func rawPathHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Write([]byte(request.URL.EscapedPath()))
+}
+
+// errorHandler 按 status 参数返回对应的错误状态码
+func errorHandler(writer http.ResponseWriter, request *http.Request) {
+	status, _ := strconv.Atoi(request.URL.Query().Get("status"))
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	writer.Write([]byte(`{"code":-1,"message":"something wrong"}`))
+}
+
+func sseEventsHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Write([]byte(": keep-alive\n\n" +
+		"retry: 3000\n" +
+		"id: 1\n" +
+		"event: delta\n" +
+		"data: {\"text\":\"hello\"}\n\n" +
+		"data:  line1\r\n" +
+		"data:line2\r\n\r\n" +
+		"id: 2\rdata: [DONE]\r\r" +
+		"data: after done\n\n"))
+}
+
+// sseReconnectHandler 根据 Last-Event-ID 续传事件，首次连接发送一条事件后断开
+func sseReconnectHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/event-stream")
+	switch request.Header.Get("Last-Event-ID") {
+	case "":
+		writer.Write([]byte("retry: 10\nid: 1\ndata: a\n\n"))
+	case "1":
+		writer.Write([]byte("id: 2\ndata: b\n\ndata: [DONE]\n\n"))
+	}
+}
+
+func ndjsonHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	writer.Write([]byte("{\"username\":\"Tom\",\"user_id\":1}\n\n{\"username\":\"Erik\",\"user_id\":2}\r\n"))
+}
+
+func jsonArrayHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write([]byte(" [{\"username\":\"Tom\",\"user_id\":1},"))
+	writer.(http.Flusher).Flush()
+	writer.Write([]byte("\n{\"username\":\"Erik\",\"user_id\":2}]"))
+}
+
+func lengthPrefixedHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/octet-stream")
+	for _, frame := range []string{"hello", "", "world\n"} {
+		var header [4]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(frame)))
+		writer.Write(header[:])
+		writer.Write([]byte(frame))
+	}
+}
+
+// sseStallHandler 发送一条事件后不再发送数据，也不关闭连接
+func sseStallHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Write([]byte("data: first\n\n"))
+	writer.(http.Flusher).Flush()
+	<-request.Context().Done()
+}
+
+// sseKeepAliveHandler 只发送保活注释，不发送事件
+func sseKeepAliveHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Write([]byte(": keep-alive\n\n"))
+	writer.(http.Flusher).Flush()
+	<-request.Context().Done()
+}
+
 var cnt = 0
 
 func retryMock(writer http.ResponseWriter, request *http.Request) {
@@ -453,3 +722,1506 @@ func TestHttpBuilder_SendWithRetry(t *testing.T) {
 	}
 	t.Log(rsp.BodyStr())
 }
+
+func TestHttpBuilder_RetryReaderBody(t *testing.T) {
+	// 第一次请求失败，记录每次发送的请求体
+	var bodies []string
+	retryOnce := func(respW Response, err error) error {
+		if err != nil {
+			return err
+		}
+		bodies = append(bodies, respW.BodyStr())
+		if len(bodies) == 1 {
+			return errors.New("retry")
+		}
+		return nil
+	}
+	_, err := NewRestGoBuilder().
+		ContentType(OctetStream).
+		Payload(bytes.NewReader([]byte("hello"))).
+		SendWithRetry(POST, "http://localhost:8080/echo", retryOnce, retry.Attempts(2), retry.Delay(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(bodies, ",") != "hello,hello" {
+		t.Fatalf("retry should resend reader payload: %q", bodies)
+	}
+
+	var res struct {
+		Files map[string][]string `json:"files"`
+	}
+	bodies = nil
+	_, err = NewRestGoBuilder().
+		FileReader("file", "a.txt", strings.NewReader("content")).
+		RspUnmarshal(&res).
+		SendWithRetry(POST, "http://localhost:8080/upload", retryOnce, retry.Attempts(2), retry.Delay(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || len(res.Files["file"]) != 1 || !strings.HasSuffix(res.Files["file"][0], ":content") {
+		t.Fatalf("retry should resend reader part: %q", res.Files["file"])
+	}
+
+	// 无法回退的 reader 重试时返回错误，不发送空的请求体
+	bodies = nil
+	_, err = NewRestGoBuilder().
+		ContentType(OctetStream).
+		Payload(io.MultiReader(strings.NewReader("once"))).
+		SendWithRetry(POST, "http://localhost:8080/echo", retryOnce, retry.Attempts(2), retry.Delay(0), retry.LastErrorOnly(true))
+	if err == nil || !strings.Contains(err.Error(), "can not be resent") || strings.Join(bodies, ",") != "once" {
+		t.Fatalf("expect resend error for non-seekable reader: %v %q", err, bodies)
+	}
+}
+
+func TestHttpBuilder_Middleware(t *testing.T) {
+	var trace []string
+	record := func(name string) Middleware {
+		return func(next RestGo) RestGo {
+			return RestGoFunc(func(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error) {
+				trace = append(trace, name+"-before")
+				rsp, err := next.Do(ctx, url, method, body, contentType, headers)
+				trace = append(trace, name+"-after")
+				return rsp, err
+			})
+		}
+	}
+	rsp, err := NewRestGoBuilder().
+		Use(record("outer"), record("inner")).
+		Send(GET, "http://localhost:8080/user/detail/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode() != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rsp.StatusCode())
+	}
+	expect := []string{"outer-before", "inner-before", "inner-after", "outer-after"}
+	if strings.Join(trace, ",") != strings.Join(expect, ",") {
+		t.Fatalf("unexpected middleware order: %v", trace)
+	}
+}
+
+func TestHttpBuilder_MiddlewareShortCircuit(t *testing.T) {
+	type resp struct {
+		Code int `json:"code"`
+	}
+	var res resp
+	rsp, err := NewRestGoBuilder().
+		Use(func(next RestGo) RestGo {
+			return RestGoFunc(func(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error) {
+				return NewResponse(http.StatusTeapot, http.Header{"X-Mock": {"1"}}, []byte(`{"code":418}`)), nil
+			})
+		}).
+		RspUnmarshal(&res).
+		Send(GET, "http://localhost:8080/user/detail/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode() != http.StatusTeapot || rsp.Header("X-Mock") != "1" || res.Code != 418 {
+		t.Fatalf("unexpected short-circuit response: %d %s %#v", rsp.StatusCode(), rsp.BodyStr(), res)
+	}
+}
+
+func TestClient_R(t *testing.T) {
+	var received http.Header
+	var receivedQuery string
+	cli := NewClient().
+		BaseUrl("http://localhost:8080").
+		Headers(map[string]string{
+			"Authorization": "Bearer token123",
+			"X-Env":         "prod",
+		}).
+		Query(map[string]string{"id": "1"}).
+		Use(func(next RestGo) RestGo {
+			return RestGoFunc(func(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error) {
+				received = make(http.Header)
+				for k, v := range headers {
+					received.Set(k, v)
+				}
+				receivedQuery = url[strings.Index(url, "?")+1:]
+				return next.Do(ctx, url, method, body, contentType, headers)
+			})
+		})
+
+	type resp struct {
+		Code int     `json:"code"`
+		Data *Person `json:"data"`
+	}
+	var res resp
+	_, err := cli.R().
+		Headers(map[string]string{"X-Env": "test"}).
+		RspUnmarshal(&res).
+		Send(GET, "/user/detail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Get("Authorization") != "Bearer token123" || received.Get("X-Env") != "test" {
+		t.Fatalf("unexpected merged headers: %v", received)
+	}
+	if receivedQuery != "id=1" || res.Data == nil || res.Data.UserId != 1 {
+		t.Fatalf("unexpected response: %s %#v", receivedQuery, res)
+	}
+
+	_, err = cli.R().
+		Query(map[string]string{"id": "2"}).
+		RspUnmarshal(&res).
+		Send(GET, "/user/detail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data == nil || res.Data.UserId != 2 {
+		t.Fatalf("request query should override client query: %#v", res)
+	}
+
+	// 请求头名称不区分大小写，当前请求的值覆盖 Client 默认值
+	rsp, err := NewClient().
+		Header("X-Token", "client").
+		R().
+		Headers(map[string]string{"x-token": "request"}).
+		Query(map[string]string{"name": "X-Token"}).
+		Send(GET, "http://localhost:8080/echo/header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "request" {
+		t.Fatalf("request header should override client header: %s", rsp.BodyStr())
+	}
+}
+
+func TestHttpBuilder_Timeout(t *testing.T) {
+	_, err := NewRestGoBuilder().
+		Timeout(50*time.Millisecond).
+		Query(map[string]string{"delay": "1s"}).
+		Send(GET, "http://localhost:8080/slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, got: %v", err)
+	}
+
+	rsp, err := NewRestGoBuilder().
+		Timeout(time.Second).
+		Query(map[string]string{"delay": "10ms"}).
+		Send(GET, "http://localhost:8080/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(rsp.BodyStr())
+}
+
+func TestHttpBuilder_StreamIdleTimeout(t *testing.T) {
+	var received []string
+	err := NewRestGoBuilder().
+		StreamIdleTimeout(100*time.Millisecond).
+		StreamSend(GET, "http://localhost:8080/sse/stall", func(resp StreamResponse, rspBody string) error {
+			received = append(received, rspBody)
+			return nil
+		})
+	if !errors.Is(err, ErrStreamIdleTimeout) {
+		t.Fatalf("expect idle timeout, got: %v", err)
+	}
+	if len(received) != 1 || received[0] != "first" {
+		t.Fatalf("unexpected events: %v", received)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	transport := NewTransport(
+		DialTimeout(time.Second),
+		MaxIdleConnsPerHost(8),
+		ResponseHeaderTimeout(2*time.Second),
+	)
+	if transport.MaxIdleConnsPerHost != 8 || transport.ResponseHeaderTimeout != 2*time.Second {
+		t.Fatalf("transport options not applied: %#v", transport)
+	}
+	if transport.IdleConnTimeout != 90*time.Second {
+		t.Fatalf("default options should be kept: %v", transport.IdleConnTimeout)
+	}
+	rsp, err := NewClient().
+		Transport(ResponseHeaderTimeout(50*time.Millisecond)).
+		R().
+		Query(map[string]string{"delay": "1s"}).
+		Send(GET, "http://localhost:8080/slow")
+	if err == nil {
+		t.Fatalf("expect response header timeout, got: %s", rsp.BodyStr())
+	}
+}
+
+func TestHttpBuilder_ExpectSuccess(t *testing.T) {
+	type apiError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	type resp struct {
+		Data *Person `json:"data"`
+	}
+	var res resp
+	var apiErr apiError
+	rsp, err := NewRestGoBuilder().
+		Query(map[string]string{"status": "404"}).
+		RspUnmarshal(&res).
+		ErrorUnmarshal(&apiErr).
+		Send(GET, "http://localhost:8080/error")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expect HTTPError, got: %v", err)
+	}
+	if httpErr.StatusCode != http.StatusNotFound || httpErr.Method != "GET" ||
+		httpErr.URL != "http://localhost:8080/error?status=404" || httpErr.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected HTTPError: %#v", httpErr)
+	}
+	if apiErr.Message != "something wrong" || rsp == nil || rsp.StatusCode() != http.StatusNotFound {
+		t.Fatalf("unexpected error body: %#v", apiErr)
+	}
+	t.Log(err)
+
+	rsp, err = NewRestGoBuilder().
+		Query(map[string]string{"status": "500"}).
+		Send(GET, "http://localhost:8080/error")
+	if err != nil {
+		t.Fatalf("non 2xx should not be an error without ExpectSuccess: %v", err)
+	}
+
+	_, err = NewClient().
+		ExpectSuccess().
+		R().
+		Query(map[string]string{"status": "500"}).
+		Send(GET, "http://localhost:8080/error")
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expect HTTPError from client policy, got: %v", err)
+	}
+}
+
+func TestSSEDecoder(t *testing.T) {
+	decoder := newSSEDecoder(strings.NewReader("\ufeffdata: a\ndata\n\nevent: ping\n\nid: 7\ndata:b\n\ndata: incomplete"))
+	var events []Event
+	for {
+		event, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	expect := []Event{
+		{Type: "message", Data: "a\n"},
+		{ID: "7", Type: "message", Data: "b"},
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected events: %#v", events)
+	}
+
+	// 只使用 CR 换行时，收到结束事件的空行后立即返回，不等待后续数据
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte("id: 1\rdata: cr\r\r"))
+	decoded := make(chan Event, 1)
+	go func() {
+		event, _ := newSSEDecoder(pr).Decode()
+		decoded <- event
+	}()
+	select {
+	case event := <-decoded:
+		if event.ID != "1" || event.Data != "cr" {
+			t.Fatalf("unexpected event: %#v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CR terminated event should be delivered without waiting for LF")
+	}
+}
+
+func TestHttpBuilder_StreamSendEvent(t *testing.T) {
+	var events []Event
+	err := NewRestGoBuilder().
+		StreamSendEvent(GET, "http://localhost:8080/sse/events", func(resp StreamResponse, event Event) error {
+			events = append(events, event)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Event{
+		{ID: "1", Type: "delta", Data: `{"text":"hello"}`, Retry: 3 * time.Second},
+		{ID: "1", Type: "message", Data: " line1\nline2", Retry: 3 * time.Second},
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expect) {
+		t.Fatalf("unexpected events: %#v", events)
+	}
+
+	var data []string
+	err = NewRestGoBuilder().
+		StreamTerminator("").
+		StreamSend(GET, "http://localhost:8080/sse/events", func(resp StreamResponse, rspBody string) error {
+			data = append(data, rspBody)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4 || data[2] != "[DONE]" || data[3] != "after done" {
+		t.Fatalf("unexpected data without terminator: %q", data)
+	}
+}
+
+func TestHttpBuilder_StreamReconnect(t *testing.T) {
+	var data []string
+	var reconnectIDs []string
+	err := NewRestGoBuilder().
+		StreamReconnect(ReconnectPolicy{
+			MaxAttempts: 3,
+			OnReconnect: func(attempt int, lastEventID string, err error) {
+				reconnectIDs = append(reconnectIDs, lastEventID)
+			},
+		}).
+		StreamSend(GET, "http://localhost:8080/sse/reconnect", func(resp StreamResponse, rspBody string) error {
+			data = append(data, rspBody)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(data, ",") != "a,b" || strings.Join(reconnectIDs, ",") != "1" {
+		t.Fatalf("unexpected reconnect result: %v %v", data, reconnectIDs)
+	}
+
+	attempts := 0
+	err = NewRestGoBuilder().
+		Query(map[string]string{"status": "400"}).
+		StreamReconnect(ReconnectPolicy{
+			OnReconnect: func(attempt int, lastEventID string, err error) {
+				attempts++
+			},
+		}).
+		StreamSend(GET, "http://localhost:8080/error", func(resp StreamResponse, rspBody string) error {
+			return nil
+		})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || attempts != 0 {
+		t.Fatalf("client errors should not reconnect: %v, attempts: %d", err, attempts)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	if d := backoffDelay(time.Second, 5*time.Second, 1); d != time.Second {
+		t.Fatal(d)
+	}
+	if d := backoffDelay(time.Second, 5*time.Second, 3); d != 4*time.Second {
+		t.Fatal(d)
+	}
+	if d := backoffDelay(time.Second, 5*time.Second, 10); d != 5*time.Second {
+		t.Fatal(d)
+	}
+}
+
+func TestHttpBuilder_Stream(t *testing.T) {
+	reader, err := NewRestGoBuilder().Stream(context.Background(), GET, "http://localhost:8080/sse/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if reader.Response() == nil || reader.Response().StatusCode() != http.StatusOK {
+		t.Fatalf("response should be available after Stream returns")
+	}
+	var data []string
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, event.Data)
+	}
+	if len(data) != 2 || data[1] != " line1\nline2" {
+		t.Fatalf("unexpected events: %q", data)
+	}
+
+	_, err = NewRestGoBuilder().
+		Query(map[string]string{"status": "502"}).
+		Stream(context.Background(), GET, "http://localhost:8080/error")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expect HTTPError, got: %v", err)
+	}
+
+	reader, err = NewRestGoBuilder().Stream(context.Background(), GET, "http://localhost:8080/sse/stall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event, err := reader.Next(); err != nil || event.Data != "first" {
+		t.Fatalf("unexpected first event: %v %v", event, err)
+	}
+	if err = reader.Close(); err != nil {
+		t.Fatalf("close should not report cancellation: %v", err)
+	}
+
+	// 没有事件时收到响应头即返回，由 Next 阻塞等待
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	reader, err = NewRestGoBuilder().Stream(ctx, GET, "http://localhost:8080/sse/keepalive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if time.Since(start) > time.Second || reader.Response() == nil || reader.Response().StatusCode() != http.StatusOK {
+		t.Fatalf("Stream should return once response headers are received")
+	}
+	if _, err = reader.Next(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Next should block until ctx is done, got: %v", err)
+	}
+}
+
+func TestHttpBuilder_StreamChan(t *testing.T) {
+	events, errCh := NewRestGoBuilder().StreamChan(context.Background(), GET, "http://localhost:8080/sse/events")
+	var data []string
+	for event := range events {
+		data = append(data, event.Data)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("unexpected events: %q", data)
+	}
+}
+
+func TestHttpBuilder_StreamDecoder(t *testing.T) {
+	for _, path := range []string{"/stream/ndjson", "/stream/array"} {
+		var persons []Person
+		err := NewRestGoBuilder().
+			StreamSendEvent(GET, "http://localhost:8080"+path, func(resp StreamResponse, event Event) error {
+				var person Person
+				if err := event.Unmarshal(&person); err != nil {
+					return err
+				}
+				persons = append(persons, person)
+				return nil
+			})
+		if err != nil {
+			t.Fatal(path, err)
+		}
+		if len(persons) != 2 || persons[0].Username != "Tom" || persons[1].UserId != 2 {
+			t.Fatalf("%s: unexpected items: %#v", path, persons)
+		}
+	}
+
+	var frames []string
+	err := NewRestGoBuilder().
+		StreamDecoder(LengthPrefixedDecoder).
+		StreamSend(GET, "http://localhost:8080/stream/frames", func(resp StreamResponse, rspBody string) error {
+			frames = append(frames, rspBody)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%q", frames) != `["hello" "" "world\n"]` {
+		t.Fatalf("unexpected frames: %q", frames)
+	}
+}
+
+func TestGenericDo(t *testing.T) {
+	type resp struct {
+		Code int     `json:"code"`
+		Data *Person `json:"data"`
+	}
+	res, rsp, err := Do[resp](NewRestGoBuilder().PathVariable(map[string]string{"id": "3"}), GET, "http://localhost:8080/user/detail/:id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode() != http.StatusOK || res.Data == nil || res.Data.Username != "Jerry" {
+		t.Fatalf("unexpected result: %#v", res)
+	}
+
+	type apiError struct {
+		Message string `json:"message"`
+	}
+	_, _, err = DoE[resp, apiError](NewRestGoBuilder().Query(map[string]string{"status": "403"}), GET, "http://localhost:8080/error")
+	var apiErr *APIError[apiError]
+	if !errors.As(err, &apiErr) || apiErr.Detail.Message != "something wrong" || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expect APIError, got: %v", err)
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("APIError should unwrap to HTTPError")
+	}
+}
+
+func TestGenericStream(t *testing.T) {
+	var persons []Person
+	err := Stream[Person](NewRestGoBuilder(), GET, "http://localhost:8080/stream/ndjson", func(resp StreamResponse, item Person) error {
+		persons = append(persons, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persons) != 2 || persons[1].Username != "Erik" {
+		t.Fatalf("unexpected items: %#v", persons)
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) ContentType() string {
+	return "text/x-upper"
+}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(fmt.Sprint(v))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*string)) = strings.ToLower(string(data))
+	return nil
+}
+
+func TestHttpBuilder_Codec(t *testing.T) {
+	type xmlPerson struct {
+		XMLName  xml.Name `xml:"person"`
+		Username string   `xml:"username"`
+		UserId   int      `xml:"user_id"`
+	}
+	var res xmlPerson
+	rsp, err := NewRestGoBuilder().
+		Codec(XMLCodec).
+		Payload(xmlPerson{Username: "Tom", UserId: 1}).
+		RspUnmarshal(&res).
+		Curl(ConsolePrint).
+		Send(POST, "http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Header("Content-Type") != "application/xml" || res.Username != "Tom" || res.UserId != 1 {
+		t.Fatalf("unexpected xml response: %s", rsp.BodyStr())
+	}
+
+	restoreCodecs(t)
+	RegisterCodec(upperCodec{})
+	var str string
+	rsp, err = NewClient().
+		Codec(upperCodec{}).
+		R().
+		Payload("hello").
+		RspUnmarshal(&str).
+		Send(POST, "http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "HELLO" || str != "hello" {
+		t.Fatalf("unexpected custom codec response: %s %s", rsp.BodyStr(), str)
+	}
+	var fromRsp string
+	if err = rsp.BodyUnmarshal(&fromRsp); err != nil || fromRsp != "hello" {
+		t.Fatalf("BodyUnmarshal should use registered codec: %s %v", fromRsp, err)
+	}
+
+	// 修改 Content-Type 后按 Content-Type 选择编解码器，不使用 Client 的编解码器
+	rsp, err = NewClient().
+		Codec(JSONCodec).
+		R().
+		ContentType(ApplicationXml).
+		Payload(xmlPerson{Username: "Tom", UserId: 1}).
+		Send(POST, "http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Header("Content-Type") != "application/xml" || !strings.HasPrefix(rsp.BodyStr(), "<?xml") {
+		t.Fatalf("payload should be encoded as xml: %s", rsp.BodyStr())
+	}
+}
+
+// restoreCodecs 测试结束后恢复已注册的编解码器，避免影响其他测试
+func restoreCodecs(t *testing.T) {
+	codecLock.RLock()
+	saved := make(map[string]Codec, len(codecs))
+	for k, v := range codecs {
+		saved[k] = v
+	}
+	codecLock.RUnlock()
+	t.Cleanup(func() {
+		codecLock.Lock()
+		defer codecLock.Unlock()
+		codecs = saved
+	})
+}
+
+func TestHttpBuilder_Xml(t *testing.T) {
+	type envelope struct {
+		XMLName xml.Name `xml:"Envelope"`
+		Body    struct {
+			Message string `xml:"Message"`
+		} `xml:"Body"`
+	}
+	var req, res envelope
+	req.Body.Message = "it's xml"
+	var curl string
+	rsp, err := NewRestGoBuilder().
+		ContentType(TextXml).
+		Payload(req).
+		RspUnmarshal(&res).
+		Curl(func(c string) {
+			curl = c
+		}).
+		Send(POST, "http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rsp.BodyStr(), xml.Header) || res.Body.Message != "it's xml" {
+		t.Fatalf("unexpected xml response: %s", rsp.BodyStr())
+	}
+	if !strings.Contains(curl, "--header 'Content-Type: text/xml'") || !strings.Contains(curl, "<Message>it&#39;s xml</Message>") {
+		t.Fatalf("unexpected curl: %s", curl)
+	}
+
+	_, err = NewRestGoBuilder().
+		ContentType(ApplicationXml).
+		BodyPayload([]byte(`<note lang='en'/>`)).
+		Curl(func(c string) {
+			curl = c
+		}).
+		ForTest().
+		Send(POST, "http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(curl, `--data-raw '<note lang='\''en'\''/>'`) {
+		t.Fatalf("single quotes should be escaped in curl: %s", curl)
+	}
+}
+
+func TestHttpBuilder_Methods(t *testing.T) {
+	rsp, err := NewRestGoBuilder().
+		JSONPatch(PatchReplace("/username", "Rose"), PatchAdd("/tags", nil), PatchRemove("/age"), PatchMove("/a", "/b")).
+		Patch("http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[{"op":"replace","path":"/username","value":"Rose"},{"op":"add","path":"/tags","value":null},{"op":"remove","path":"/age"},{"from":"/a","op":"move","path":"/b"}]`
+	if rsp.Header("Content-Type") != string(JsonPatchJson) || rsp.BodyStr() != expect {
+		t.Fatalf("unexpected json patch: %s %s", rsp.Header("Content-Type"), rsp.BodyStr())
+	}
+
+	var merged map[string]interface{}
+	rsp, err = NewRestGoBuilder().
+		MergePatch(map[string]interface{}{"username": "Rose", "age": nil}).
+		RspUnmarshal(&merged).
+		Patch("http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Header("Content-Type") != string(MergePatchJson) || merged["username"] != "Rose" {
+		t.Fatalf("unexpected merge patch: %s", rsp.BodyStr())
+	}
+
+	var res map[string]interface{}
+	rsp, err = NewRestGoBuilder().RspUnmarshal(&res).Head("http://localhost:8080/user/detail/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode() != http.StatusOK || len(rsp.Body()) != 0 {
+		t.Fatalf("HEAD response should not have body: %s", rsp.BodyStr())
+	}
+
+	rsp, err = NewRestGoBuilder().
+		CorsPreflight("http://example.com", PUT, "Content-Type", "X-Token").
+		RspUnmarshal(&res).
+		Options("http://localhost:8080/cors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode() != http.StatusNoContent || rsp.Header("Access-Control-Allow-Origin") != "http://example.com" ||
+		rsp.Header("Access-Control-Allow-Methods") != "PUT" || rsp.Header("Access-Control-Allow-Headers") != "Content-Type, X-Token" {
+		t.Fatalf("unexpected preflight response: %d", rsp.StatusCode())
+	}
+}
+
+type orderStatus string
+
+func (s orderStatus) String() string {
+	return strings.ToUpper(string(s))
+}
+
+func TestHttpBuilder_QueryValues(t *testing.T) {
+	type pageQuery struct {
+		Page int `query:"page,omitempty"`
+		Size int `query:"size"`
+	}
+	type listQuery struct {
+		pageQuery
+		IDs     []int         `query:"ids,comma"`
+		Tags    []string      `query:"tag,brackets"`
+		Keyword string        `query:"q,omitempty"`
+		Status  orderStatus   `query:"status"`
+		Since   *time.Time    `query:"since"`
+		Ignored string        `query:"-"`
+		Timeout time.Duration `query:"timeout,omitempty"`
+	}
+	rsp, err := NewRestGoBuilder().
+		QueryStruct(listQuery{
+			pageQuery: pageQuery{Size: 20},
+			IDs:       []int{1, 2},
+			Tags:      []string{"a b", "c&d"},
+			Status:    "paid",
+		}).
+		AddQuery("x", "1").
+		AddQuery("x", "2").
+		Query(map[string]string{"name": "张三"}).
+		Send(GET, "http://localhost:8080/query?size=10&keep=yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "ids=1,2&keep=yes&name=%E5%BC%A0%E4%B8%89&size=20&status=PAID&tag%5B%5D=a+b&tag%5B%5D=c%26d&x=1&x=2"
+	if rsp.BodyStr() != expect {
+		t.Fatalf("unexpected query:\n%s\n%s", rsp.BodyStr(), expect)
+	}
+
+	rsp, err = NewRestGoBuilder().
+		QueryArrayStyle(ArrayComma).
+		SetQuery("x", "1", "2").
+		SetQuery("x", "3", "4").
+		Send(GET, "http://localhost:8080/query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "x=3,4" {
+		t.Fatalf("unexpected query: %s", rsp.BodyStr())
+	}
+
+	// 只有一个值时数组字段仍然按指定的方式编码，普通参数不受影响
+	type singleQuery struct {
+		IDs  []int    `query:"ids,brackets"`
+		Tags []string `query:"tag"`
+		Page int      `query:"page"`
+	}
+	rsp, err = NewRestGoBuilder().
+		QueryArrayStyle(ArrayBrackets).
+		QueryStruct(singleQuery{IDs: []int{1}, Tags: []string{"a"}, Page: 1}).
+		SetQuery("name", "x").
+		Send(GET, "http://localhost:8080/query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "ids%5B%5D=1&name=x&page=1&tag%5B%5D=a" {
+		t.Fatalf("unexpected single value query: %s", rsp.BodyStr())
+	}
+
+	// URL 中的参数优先于 Client 默认参数
+	rsp, err = NewClient().
+		Query(map[string]string{"page": "1", "size": "10"}).
+		R().
+		Send(GET, "http://localhost:8080/query?page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "page=2&size=10" {
+		t.Fatalf("url query should override client defaults: %s", rsp.BodyStr())
+	}
+
+	_, err = NewRestGoBuilder().QueryStruct(1).Send(GET, "http://localhost:8080/query")
+	if err == nil {
+		t.Fatal("expect error for unsupported query struct")
+	}
+
+	type nestedQuery struct {
+		Matrix [][]int `query:"m"`
+	}
+	_, err = NewRestGoBuilder().QueryStruct(nestedQuery{Matrix: [][]int{{1, 2}}}).Send(GET, "http://localhost:8080/query")
+	if err == nil || !strings.Contains(err.Error(), "unsupported query value type") {
+		t.Fatalf("expect error for nested slice, got: %v", err)
+	}
+}
+
+func TestHttpBuilder_PathParam(t *testing.T) {
+	rsp, err := NewRestGoBuilder().
+		PathParam("tenant", "a b/c").
+		PathParam("id", 42).
+		PathParam("status", orderStatus("paid")).
+		RawPathParam("rest", "x/y").
+		Send(GET, "http://localhost:8080/path/:tenant/users/{id}.json/{status}/{rest}?q={id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "/path/a%20b%2Fc/users/42.json/PAID/x/y" {
+		t.Fatalf("unexpected path: %s", rsp.BodyStr())
+	}
+
+	_, err = NewRestGoBuilder().
+		PathParam("id", 1).
+		Send(GET, "http://localhost:8080/path/{tenant}/users/:id/{name}")
+	if err == nil || err.Error() != "path val [tenant, name] not set" {
+		t.Fatalf("expect all missing path vals, got: %v", err)
+	}
+
+	_, err = NewRestGoBuilder().Send(GET, "http://localhost:8080/users/{id}/{x}")
+	if err == nil || err.Error() != "path val [id, x] not set" {
+		t.Fatalf("unexpected error without path params: %v", err)
+	}
+
+	// 无法格式化的类型退化为默认格式
+	rsp, err = NewRestGoBuilder().
+		PathParam("ids", []string{"a", "b"}).
+		Send(GET, "http://localhost:8080/path/{ids}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "/path/%5Ba%20b%5D" {
+		t.Fatalf("unexpected path for slice param: %s", rsp.BodyStr())
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	cases := []struct {
+		base, ref, expect string
+	}{
+		{"", "/user/detail", "/user/detail"},
+		{"http://localhost:8080", "/user/detail", "http://localhost:8080/user/detail"},
+		{"http://localhost:8080/", "/user/detail", "http://localhost:8080/user/detail"},
+		{"http://api.example.com/api/v2", "users", "http://api.example.com/api/v2/users"},
+		{"http://api.example.com/api/v2/", "/users", "http://api.example.com/api/v2/users"},
+		{"http://api.example.com/api/v2", "../v1/users", "http://api.example.com/api/v1/users"},
+		{"http://api.example.com/api", "https://other.example.com/x", "https://other.example.com/x"},
+		{"http://api.example.com/api?token=1&lang=en", "users?lang=zh", "http://api.example.com/api/users?lang=zh&token=1"},
+		{"http://api.example.com/api", "users/a%2Fb#top", "http://api.example.com/api/users/a%2Fb#top"},
+		{"http://api.example.com/api", "user:1", "http://api.example.com/api/user:1"},
+		{"http://api.example.com/api", "?a=1", "http://api.example.com/api?a=1"},
+		{"http://api.example.com/api?token=1", "#top", "http://api.example.com/api?token=1#top"},
+	}
+	for _, c := range cases {
+		joined, err := joinURL(c.base, c.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if joined != c.expect {
+			t.Errorf("joinURL(%q, %q) = %q, expect %q", c.base, c.ref, joined, c.expect)
+		}
+	}
+}
+
+func TestHttpBuilder_BaseUrlQuery(t *testing.T) {
+	rsp, err := NewRestGoBuilder().
+		BaseUrl("http://localhost:8080/?token=abc").
+		Query(map[string]string{"id": "1"}).
+		Send(GET, "query?lang=zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "id=1&lang=zh&token=abc" {
+		t.Fatalf("unexpected query: %s", rsp.BodyStr())
+	}
+}
+
+func TestHttpBuilder_StreamBody(t *testing.T) {
+	// 长度未知的 reader 使用分块传输
+	var echo []byte
+	reader := io.MultiReader(strings.NewReader("hello "), strings.NewReader("stream"))
+	rsp, err := NewRestGoBuilder().
+		ContentType(OctetStream).
+		Payload(reader).
+		Send(POST, "http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo = rsp.Body()
+	if string(echo) != "hello stream" {
+		t.Fatalf("unexpected echo body: %s", echo)
+	}
+
+	// 表单文件长度已知时设置 Content-Length
+	var res struct {
+		ContentLength int64               `json:"content_length"`
+		Fields        map[string][]string `json:"fields"`
+		Files         map[string][]string `json:"files"`
+	}
+	_, err = NewRestGoBuilder().
+		ContentType(FormData).
+		Payload(map[string]string{"user": "erik"}).
+		FileReader("cover", "cover.txt", bytes.NewReader([]byte("file content"))).
+		RspUnmarshal(&res).
+		Send(POST, "http://localhost:8080/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ContentLength <= 0 {
+		t.Fatalf("expected content length, got %d", res.ContentLength)
+	}
+	if res.Files["cover"][0] != "cover.txt:application/octet-stream::file content" || res.Fields["user"][0] != "erik" {
+		t.Fatalf("unexpected form: %#v", res)
+	}
+}
+
+func TestHttpBuilder_MultipartFiles(t *testing.T) {
+	var res struct {
+		Fields map[string][]string `json:"fields"`
+		Files  map[string][]string `json:"files"`
+	}
+	var curl string
+	_, err := NewRestGoBuilder().
+		Payload(map[string]interface{}{
+			"user":  "erik",
+			"age":   18,
+			"tags":  []string{"a", "b"},
+			"extra": map[string]int{"level": 1},
+		}).
+		File("docs", "testdata/formfile.txt").
+		FileReader("docs", "second.txt", strings.NewReader("second")).
+		FormFiles(FormFile{
+			Key:         "avatar",
+			FileName:    "avatar.json",
+			Reader:      strings.NewReader(`{}`),
+			ContentType: "application/json",
+			Header:      map[string]string{"X-Part": "1"},
+		}).
+		Curl(func(s string) { curl = s }).
+		RspUnmarshal(&res).
+		Send(POST, "http://localhost:8080/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Files["docs"]; len(got) != 2 || got[0] != "formfile.txt:application/octet-stream::hello restgo\n" || got[1] != "second.txt:application/octet-stream::second" {
+		t.Fatalf("unexpected docs files: %#v", got)
+	}
+	if got := res.Files["avatar"]; len(got) != 1 || got[0] != "avatar.json:application/json:1:{}" {
+		t.Fatalf("unexpected avatar file: %#v", got)
+	}
+	if res.Fields["age"][0] != "18" || len(res.Fields["tags"]) != 2 || res.Fields["extra"][0] != `{"level":1}` {
+		t.Fatalf("unexpected fields: %#v", res.Fields)
+	}
+	for _, part := range []string{
+		`--form 'age="18"'`,
+		`--form 'tags="b"'`,
+		`--form 'docs=@"testdata/formfile.txt"'`,
+		`--form 'docs=@"second.txt"'`,
+		`--form 'avatar=@"avatar.json";type=application/json;headers="X-Part: 1"'`,
+	} {
+		if !strings.Contains(curl, part) {
+			t.Fatalf("curl missing %s:\n%s", part, curl)
+		}
+	}
+}
+
+func TestHttpBuilder_Progress(t *testing.T) {
+	payload := bytes.Repeat([]byte("restgo"), 1<<16)
+	var uploaded, downloaded, uploadTotal int64
+	rsp, err := NewRestGoBuilder().
+		ContentType(OctetStream).
+		Payload(payload).
+		ProgressInterval(time.Millisecond).
+		UploadProgress(func(sent, total int64) {
+			uploaded, uploadTotal = sent, total
+		}).
+		DownloadProgress(func(recv, total int64) {
+			downloaded = recv
+		}).
+		Send(POST, "http://localhost:8080/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(payload))
+	if len(rsp.Body()) != len(payload) {
+		t.Fatalf("unexpected body size %d", len(rsp.Body()))
+	}
+	if uploaded != size || uploadTotal != size {
+		t.Fatalf("unexpected upload progress %d/%d", uploaded, uploadTotal)
+	}
+	// 响应为分块传输，总长度未知
+	if downloaded != size {
+		t.Fatalf("unexpected download progress %d", downloaded)
+	}
+
+	// 流式请求统计已读取的响应体
+	var streamed int64
+	var events int
+	err = NewRestGoBuilder().
+		DownloadProgress(func(recv, total int64) {
+			streamed = recv
+		}).
+		StreamDecoder(NDJSONDecoder).
+		StreamSendEvent(GET, "http://localhost:8080/stream/ndjson", func(resp StreamResponse, event Event) error {
+			events++
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events == 0 || streamed == 0 {
+		t.Fatalf("unexpected stream progress: events %d, bytes %d", events, streamed)
+	}
+}
+
+func TestHttpBuilder_Output(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha256.Sum256([]byte(downloadContent))
+	checksum := hex.EncodeToString(sum[:])
+
+	path := filepath.Join(dir, "sub", "download.txt")
+	if _, err := NewRestGoBuilder().
+		OutputFile(path).
+		ExpectSHA256(checksum).
+		Send(GET, "http://localhost:8080/download"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != downloadContent {
+		t.Fatalf("unexpected file size %d", len(content))
+	}
+	if _, err = os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("part file should be renamed, got %v", err)
+	}
+
+	// 校验和不一致时删除临时文件
+	badPath := filepath.Join(dir, "bad.txt")
+	_, err = NewRestGoBuilder().
+		OutputFile(badPath).
+		ExpectSHA256(strings.Repeat("0", 64)).
+		Send(GET, "http://localhost:8080/download")
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err = os.Stat(badPath + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("part file should be removed, got %v", err)
+	}
+
+	// 断点续传
+	resumePath := filepath.Join(dir, "resume.txt")
+	if err = os.WriteFile(resumePath+".part", []byte(downloadContent[:1234]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var rangeHeader string
+	if _, err = NewRestGoBuilder().
+		OutputFile(resumePath).
+		ResumeDownload().
+		ExpectSHA256(checksum).
+		Use(func(next RestGo) RestGo {
+			return RestGoFunc(func(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error) {
+				rangeHeader = headers["Range"]
+				return next.Do(ctx, url, method, body, contentType, headers)
+			})
+		}).
+		Send(GET, "http://localhost:8080/download"); err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(resumePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rangeHeader != "bytes=1234-" || string(content) != downloadContent {
+		t.Fatalf("unexpected resume result, range %q, size %d", rangeHeader, len(content))
+	}
+
+	// 写入 writer
+	var buf bytes.Buffer
+	rsp, err := NewRestGoBuilder().
+		OutputWriter(&buf).
+		Send(GET, "http://localhost:8080/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != downloadContent || len(rsp.Body()) != 0 {
+		t.Fatalf("unexpected writer output %d, body %d", buf.Len(), len(rsp.Body()))
+	}
+
+	// 下载失败时返回 HTTPError
+	_, err = NewRestGoBuilder().
+		OutputWriter(&buf).
+		Send(GET, "http://localhost:8080/error?status=404")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 HTTPError, got %v", err)
+	}
+}
+
+func TestHttpBuilder_RemoteFile(t *testing.T) {
+	var res struct {
+		Files map[string][]string `json:"files"`
+	}
+	_, err := NewRestGoBuilder().
+		File("doc", "http://localhost:8080/download?name=report.txt").
+		File("raw", "http://localhost:8080/download").
+		RspUnmarshal(&res).
+		Send(POST, "http://localhost:8080/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Files["doc"]; len(got) != 1 || got[0] != "report.txt:text/plain; charset=utf-8::"+downloadContent {
+		t.Fatalf("unexpected remote file: %.80q", got)
+	}
+	if got := res.Files["raw"]; len(got) != 1 || !strings.HasPrefix(got[0], "download:") {
+		t.Fatalf("unexpected remote file name: %.80q", got)
+	}
+
+	_, err = NewRestGoBuilder().
+		File("doc", "http://localhost:8080/download").
+		RemoteFile(RemoteFilePolicy{MaxSize: 100}).
+		Send(POST, "http://localhost:8080/upload")
+	if !errors.Is(err, ErrRemoteFileTooLarge) {
+		t.Fatalf("expected remote file too large, got %v", err)
+	}
+
+	for _, path := range []string{"ftp://localhost/download", "http://localhost:8080/download"} {
+		_, err = NewRestGoBuilder().
+			File("doc", path).
+			RemoteFile(RemoteFilePolicy{AllowedHosts: []string{"*.example.com"}}).
+			Send(POST, "http://localhost:8080/upload")
+		if err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Fatalf("expected %s not allowed, got %v", path, err)
+		}
+	}
+
+	// 下载链接无响应时受当前请求的超时控制
+	start := time.Now()
+	_, err = NewClient().
+		R().
+		Timeout(200*time.Millisecond).
+		File("doc", "http://localhost:8080/slow?delay=5s").
+		Send(POST, "http://localhost:8080/upload")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 2*time.Second {
+		t.Fatalf("stalled remote file should time out, got %v after %v", err, time.Since(start))
+	}
+
+	// 重定向后的地址同样需要满足限制
+	policy := RemoteFilePolicy{AllowedHosts: []string{"localhost"}}
+	_, err = NewRestGoBuilder().
+		File("doc", "http://localhost:8080/redirect/to?url="+url.QueryEscape("http://localhost:8080/download?name=a.txt")).
+		RemoteFile(policy).
+		RspUnmarshal(&res).
+		Send(POST, "http://localhost:8080/upload")
+	if err != nil || len(res.Files["doc"]) != 1 || !strings.HasPrefix(res.Files["doc"][0], "a.txt:") {
+		t.Fatalf("redirect to allowed host should succeed: %v %.80q", err, res.Files["doc"])
+	}
+	_, err = NewRestGoBuilder().
+		File("doc", "http://localhost:8080/redirect/to?url="+url.QueryEscape("http://127.0.0.1:8080/download")).
+		RemoteFile(policy).
+		Send(POST, "http://localhost:8080/upload")
+	if err == nil || !strings.Contains(err.Error(), "host [127.0.0.1] not allowed") {
+		t.Fatalf("redirect to disallowed host should fail, got %v", err)
+	}
+}
+
+func TestHttpBuilder_RawResponse(t *testing.T) {
+	rsp, err := NewRestGoBuilder().
+		RawResponse().
+		Timeout(time.Second).
+		Send(GET, "http://localhost:8080/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := rsp.BodyReader()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = reader.Close()
+	if string(content) != downloadContent {
+		t.Fatalf("unexpected raw body size %d", len(content))
+	}
+	if rsp.Body() != nil {
+		t.Fatal("body should be consumed by BodyReader")
+	}
+
+	// 调用 Body 时才读取
+	rsp, err = NewRestGoBuilder().
+		RawResponse().
+		Send(GET, "http://localhost:8080/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != downloadContent {
+		t.Fatalf("unexpected lazy body size %d", len(rsp.Body()))
+	}
+
+	// 超过最大长度
+	_, err = NewRestGoBuilder().
+		MaxBodySize(100).
+		Send(GET, "http://localhost:8080/download")
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected body too large, got %v", err)
+	}
+	var res map[string]interface{}
+	_, err = NewClient().
+		MaxBodySize(100).
+		R().
+		RawResponse().
+		RspUnmarshal(&res).
+		Send(GET, "http://localhost:8080/download")
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected body too large, got %v", err)
+	}
+}
+
+func TestResponse_Details(t *testing.T) {
+	rsp, err := NewRestGoBuilder().Send(GET, "http://localhost:8080/redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rsp.HeaderValues("Link"); len(got) != 2 {
+		t.Fatalf("unexpected link headers: %v", got)
+	}
+	if got := rsp.Headers().Values("Set-Cookie"); len(got) != 2 {
+		t.Fatalf("unexpected headers: %v", rsp.Headers())
+	}
+	cookies := rsp.Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "session" || cookies[1].Value != "dark" {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+	if rsp.URL() == nil || rsp.URL().Query().Get("from") != "redirect" || rsp.Request().Method != "GET" {
+		t.Fatalf("unexpected final url: %v", rsp.URL())
+	}
+	if rsp.ContentLength() != 2 || rsp.TLS() != nil || rsp.Duration() <= 0 {
+		t.Fatalf("unexpected length %d, tls %v, duration %v", rsp.ContentLength(), rsp.TLS(), rsp.Duration())
+	}
+
+	var streamResp StreamResponse
+	err = NewRestGoBuilder().
+		StreamSendEvent(GET, "http://localhost:8080/sse/events", func(resp StreamResponse, event Event) error {
+			streamResp = resp
+			return ErrStopStream
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamResp.Headers().Get("Content-Type") == "" || streamResp.URL().Path != "/sse/events" || streamResp.Duration() <= 0 {
+		t.Fatalf("unexpected stream response: %v %v", streamResp.Headers(), streamResp.URL())
+	}
+
+	var empty Response = new(EmptyResponse)
+	if empty.Headers() != nil || empty.URL() != nil || empty.Cookies() != nil || empty.ContentLength() != -1 {
+		t.Fatal("empty response should not have details")
+	}
+	if synthetic := NewResponse(http.StatusOK, nil, nil); synthetic.URL() != nil || synthetic.Request() != nil {
+		t.Fatal("synthetic response should not have request")
+	}
+}
+
+func TestHttpBuilder_EnableTrace(t *testing.T) {
+	// 独立的连接池，保证第一次请求需要建连
+	client := NewClient().Transport()
+	rsp, err := client.R().EnableTrace().Send(GET, "http://localhost:8080/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := rsp.TraceInfo()
+	if info.ConnReused || info.TCPConnect <= 0 || info.TimeToFirstByte <= 0 || info.Total < info.TimeToFirstByte || info.RemoteAddr == "" {
+		t.Fatalf("unexpected first trace: %+v", info)
+	}
+
+	rsp, err = client.R().EnableTrace().Send(GET, "http://localhost:8080/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info = rsp.TraceInfo()
+	if !info.ConnReused || !info.ConnWasIdle || info.TCPConnect != 0 {
+		t.Fatalf("unexpected reused trace: %+v", info)
+	}
+
+	rsp, err = client.R().Send(GET, "http://localhost:8080/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.TraceInfo() != (TraceInfo{}) {
+		t.Fatalf("trace should be disabled: %+v", rsp.TraceInfo())
+	}
+}
+
+func TestHttpBuilder_Compression(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "br"} {
+		rsp, err := NewRestGoBuilder().Send(GET, "http://localhost:8080/compressed?encoding="+encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.BodyStr() != downloadContent || rsp.Header("Content-Encoding") != "" {
+			t.Fatalf("%s: unexpected body size %d", encoding, len(rsp.Body()))
+		}
+		if !strings.HasPrefix(rsp.Header("X-Accept-Encoding"), "gzip, deflate, br") {
+			t.Fatalf("unexpected accept-encoding %s", rsp.Header("X-Accept-Encoding"))
+		}
+	}
+
+	// 保留压缩的响应体
+	rsp, err := NewRestGoBuilder().
+		KeepCompressed().
+		Send(GET, "http://localhost:8080/compressed?encoding=gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Header("Content-Encoding") != "gzip" || !bytes.HasPrefix(rsp.Body(), []byte{0x1f, 0x8b}) {
+		t.Fatalf("expected raw gzip body")
+	}
+
+	// 注册自定义的解压方式
+	restoreDecompressors(t)
+	RegisterDecompressor("reverse", func(r io.Reader) (io.ReadCloser, error) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+	rsp, err = NewRestGoBuilder().Send(GET, "http://localhost:8080/compressed?encoding=reverse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != downloadContent || !strings.Contains(rsp.Header("X-Accept-Encoding"), "reverse") {
+		t.Fatalf("unexpected custom decoding, accept-encoding %s", rsp.Header("X-Accept-Encoding"))
+	}
+
+	// 压缩请求体
+	rsp, err = NewRestGoBuilder().
+		Payload(map[string]string{"content": downloadContent}).
+		CompressRequest("gzip").
+		Send(POST, "http://localhost:8080/decompress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode() != http.StatusOK || !strings.Contains(rsp.BodyStr(), downloadContent) {
+		t.Fatalf("unexpected decompressed request: %d", rsp.StatusCode())
+	}
+	if _, err = NewRestGoBuilder().CompressRequest("unknown").Payload(map[string]string{}).Send(POST, "http://localhost:8080/decompress"); err == nil {
+		t.Fatal("expected unsupported content-encoding error")
+	}
+
+	// curl 中输出压缩后的请求体，通过 base64 -d 还原后发送
+	var curl string
+	_, err = NewRestGoBuilder().
+		Payload(map[string]string{"content": "curl"}).
+		CompressRequest("gzip").
+		Curl(func(c string) {
+			curl = c
+		}).
+		ForTest().
+		Send(POST, "http://localhost:8080/decompress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(curl, "| base64 -d | curl ") || !strings.Contains(curl, "--data-binary @-") || strings.Contains(curl, "--data-raw") {
+		t.Fatalf("unexpected compressed curl: %s", curl)
+	}
+	encoded := strings.TrimPrefix(curl[:strings.Index(curl, "' | base64")], "printf '%s' '")
+	compressed, _ := base64.StdEncoding.DecodeString(encoded)
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _ := io.ReadAll(gz); string(plain) != `{"content":"curl"}` {
+		t.Fatalf("unexpected curl payload: %s", plain)
+	}
+
+	// 请求体为空时不压缩，也不设置 Content-Encoding
+	var sentEncoding string
+	_, err = NewRestGoBuilder().
+		CompressRequest("gzip").
+		Use(func(next RestGo) RestGo {
+			return RestGoFunc(func(ctx context.Context, url string, method string, body io.Reader, contentType string, headers map[string]string) (Response, error) {
+				sentEncoding = headers["Content-Encoding"]
+				return next.Do(ctx, url, method, body, contentType, headers)
+			})
+		}).
+		Send(GET, "http://localhost:8080/headers")
+	if err != nil || sentEncoding != "" {
+		t.Fatalf("empty body should not set content-encoding: %q %v", sentEncoding, err)
+	}
+}
+
+// restoreDecompressors 测试结束后恢复已注册的解压方式，避免其他请求的 Accept-Encoding 带上测试注册的编码
+func restoreDecompressors(t *testing.T) {
+	compressionLock.RLock()
+	savedOrder := append([]string(nil), decodingOrder...)
+	saved := make(map[string]Decompressor, len(decompressors))
+	for k, v := range decompressors {
+		saved[k] = v
+	}
+	compressionLock.RUnlock()
+	t.Cleanup(func() {
+		compressionLock.Lock()
+		defer compressionLock.Unlock()
+		decodingOrder, decompressors = savedOrder, saved
+	})
+}
+
+func TestHttpBuilder_Charset(t *testing.T) {
+	var person Person
+	rsp, err := NewRestGoBuilder().
+		RspUnmarshal(&person).
+		Send(GET, "http://localhost:8080/charset?type=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Username != "张三" || !strings.Contains(rsp.BodyStr(), "张三") {
+		t.Fatalf("unexpected gbk json: %#v, %s", person, rsp.BodyStr())
+	}
+
+	rsp, err = NewRestGoBuilder().Send(GET, "http://localhost:8080/charset?type=latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != "café" {
+		t.Fatalf("unexpected latin1 text: %s", rsp.BodyStr())
+	}
+
+	// Content-Type 没有指定字符集时从 meta 标签识别
+	rsp, err = NewRestGoBuilder().SniffCharset().Send(GET, "http://localhost:8080/charset?type=html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rsp.BodyStr(), "你好") {
+		t.Fatalf("unexpected sniffed html: %s", rsp.BodyStr())
+	}
+
+	// XML 声明中的编码
+	for _, builder := range []*Builder{NewRestGoBuilder(), NewRestGoBuilder().SniffCharset()} {
+		var xmlPerson struct {
+			XMLName  xml.Name `xml:"person"`
+			Username string   `xml:"username"`
+			UserId   int      `xml:"user_id"`
+		}
+		rsp, err = builder.RspUnmarshal(&xmlPerson).Send(GET, "http://localhost:8080/charset?type=xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if xmlPerson.Username != "李四" || xmlPerson.UserId != 2 {
+			t.Fatalf("unexpected gbk xml: %#v", xmlPerson)
+		}
+	}
+
+	// 不转换字符集
+	rsp, err = NewRestGoBuilder().RawCharset().Send(GET, "http://localhost:8080/charset?type=latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.BodyStr() != string([]byte{'c', 'a', 'f', 0xe9}) {
+		t.Fatalf("unexpected raw text: %q", rsp.BodyStr())
+	}
+
+	// 自定义字符集
+	restoreCharsets(t)
+	RegisterCharset("x-upper", func(r io.Reader) io.Reader {
+		data, _ := io.ReadAll(r)
+		return strings.NewReader(strings.ToUpper(string(data)))
+	})
+	synthetic := NewResponse(http.StatusOK, http.Header{"Content-Type": []string{"text/plain; charset=x-upper"}}, []byte("restgo"))
+	if synthetic.BodyStr() != "RESTGO" || string(synthetic.Body()) != "restgo" {
+		t.Fatalf("unexpected custom charset: %s", synthetic.BodyStr())
+	}
+}
+
+// restoreCharsets 测试结束后恢复已注册的字符集
+func restoreCharsets(t *testing.T) {
+	charsetLock.RLock()
+	saved := make(map[string]CharsetDecoder, len(charsetDecoders))
+	for k, v := range charsetDecoders {
+		saved[k] = v
+	}
+	charsetLock.RUnlock()
+	t.Cleanup(func() {
+		charsetLock.Lock()
+		defer charsetLock.Unlock()
+		charsetDecoders = saved
+	})
+}
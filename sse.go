@@ -0,0 +1,151 @@
+package restgo
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event 流式响应中的一条消息，SSE 格式见 https://html.spec.whatwg.org/multipage/server-sent-events.html
+type Event struct {
+	// ID 最近一次收到的事件ID，即重连时需要携带的 Last-Event-ID
+	ID string
+	// Type 事件类型，未指定时为 message
+	Type string
+	// Data 事件数据，多个 data 字段之间以换行符连接
+	Data string
+	// Retry 服务端最近一次指定的重连间隔，未指定时为0
+	Retry time.Duration
+}
+
+const defaultEventType = "message"
+
+// sseDecoder 按照 SSE 规范逐条解析事件
+type sseDecoder struct {
+	reader      *bufio.Reader
+	eof         bool
+	started     bool
+	skipLF      bool
+	lastEventID string
+	retry       time.Duration
+}
+
+func newSSEDecoder(r io.Reader) *sseDecoder {
+	return &sseDecoder{reader: bufio.NewReader(r)}
+}
+
+// Decode 读取下一条事件，流结束时返回 io.EOF，结束时未以空行收尾的事件会被丢弃
+func (d *sseDecoder) Decode() (Event, error) {
+	var data strings.Builder
+	var eventType string
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		// 空行表示一条事件结束
+		if line == "" {
+			if data.Len() == 0 {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = defaultEventType
+			}
+			return Event{
+				ID:    d.lastEventID,
+				Type:  eventType,
+				Data:  strings.TrimSuffix(data.String(), "\n"),
+				Retry: d.retry,
+			}, nil
+		}
+
+		// 注释行
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastEventID = value
+			}
+		case "retry":
+			if isASCIIDigits(value) {
+				if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+					d.retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+	}
+}
+
+// readLine 读取一行，兼容 CRLF、LF、CR 三种换行符，读到换行符后立即返回，不等待后续数据
+func (d *sseDecoder) readLine() (string, error) {
+	if d.eof {
+		return "", io.EOF
+	}
+	var line []byte
+	for {
+		b, err := d.reader.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				return "", err
+			}
+			d.eof = true
+			// 最后一行没有换行符时同样返回
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+			return d.trimBOM(line), nil
+		}
+		switch b {
+		case '\n':
+			// CRLF 中的 LF 已随 CR 结束当前行
+			if d.skipLF {
+				d.skipLF = false
+				continue
+			}
+			return d.trimBOM(line), nil
+		case '\r':
+			d.skipLF = true
+			return d.trimBOM(line), nil
+		default:
+			d.skipLF = false
+			line = append(line, b)
+		}
+	}
+}
+
+// trimBOM 去掉流开头的 UTF-8 BOM
+func (d *sseDecoder) trimBOM(line []byte) string {
+	if !d.started {
+		d.started = true
+		return strings.TrimPrefix(string(line), "\ufeff")
+	}
+	return string(line)
+}
+
+func isASCIIDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package restgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// StreamDecoder 将流式响应体逐条解析为 Event，流结束时返回 io.EOF
+type StreamDecoder interface {
+	Decode() (Event, error)
+}
+
+// StreamDecoderFactory 根据响应体创建 StreamDecoder
+type StreamDecoderFactory func(r io.Reader) StreamDecoder
+
+// maxFrameSize 长度前缀格式单帧的最大长度，防止异常数据导致申请过大的内存
+const maxFrameSize = 64 << 20
+
+var (
+	// SSEDecoder Server-Sent Events，text/event-stream
+	SSEDecoder StreamDecoderFactory = func(r io.Reader) StreamDecoder {
+		return newSSEDecoder(r)
+	}
+	// NDJSONDecoder 换行分隔的 JSON（NDJSON / JSON Lines），每行一条，忽略空行
+	NDJSONDecoder StreamDecoderFactory = func(r io.Reader) StreamDecoder {
+		return &lineDecoder{reader: bufio.NewReader(r), skipEmpty: true}
+	}
+	// LineDecoder 按行切分的纯文本，每行一条
+	LineDecoder StreamDecoderFactory = func(r io.Reader) StreamDecoder {
+		return &lineDecoder{reader: bufio.NewReader(r)}
+	}
+	// LengthPrefixedDecoder 每帧以4字节大端序的长度开头，后面跟随对应长度的数据
+	LengthPrefixedDecoder StreamDecoderFactory = func(r io.Reader) StreamDecoder {
+		return &lengthPrefixedDecoder{reader: bufio.NewReader(r)}
+	}
+	// JSONArrayDecoder 分块传输的 JSON 数组，数组中的每个元素为一条，响应不是数组时整体作为一条
+	JSONArrayDecoder StreamDecoderFactory = func(r io.Reader) StreamDecoder {
+		return &jsonArrayDecoder{reader: bufio.NewReader(r)}
+	}
+)
+
+var (
+	streamDecoderLock sync.RWMutex
+	streamDecoders    = map[string]StreamDecoderFactory{
+		"text/event-stream":       SSEDecoder,
+		"application/x-ndjson":    NDJSONDecoder,
+		"application/ndjson":      NDJSONDecoder,
+		"application/jsonl":       NDJSONDecoder,
+		"application/json-lines":  NDJSONDecoder,
+		"application/x-jsonlines": NDJSONDecoder,
+		"application/json":        JSONArrayDecoder,
+		"text/plain":              LineDecoder,
+	}
+)
+
+// RegisterStreamDecoder 注册响应 Content-Type 对应的解析器，已存在时覆盖
+func RegisterStreamDecoder(mediaType string, factory StreamDecoderFactory) {
+	streamDecoderLock.Lock()
+	defer streamDecoderLock.Unlock()
+	streamDecoders[strings.ToLower(mediaType)] = factory
+}
+
+// lookupStreamDecoder 根据响应 Content-Type 查找解析器，找不到时按 SSE 处理
+func lookupStreamDecoder(contentType string) StreamDecoderFactory {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		streamDecoderLock.RLock()
+		factory, ok := streamDecoders[mediaType]
+		streamDecoderLock.RUnlock()
+		if ok {
+			return factory
+		}
+	}
+	return SSEDecoder
+}
+
+// Unmarshal 将事件数据按 JSON 反序列化到 v
+func (e Event) Unmarshal(v interface{}) error {
+	return json.Unmarshal([]byte(e.Data), v)
+}
+
+type lineDecoder struct {
+	reader    *bufio.Reader
+	skipEmpty bool
+}
+
+func (d *lineDecoder) Decode() (Event, error) {
+	for {
+		line, err := d.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return Event{}, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if d.skipEmpty && strings.TrimSpace(line) == "" {
+			continue
+		}
+		return Event{Data: line}, nil
+	}
+}
+
+type lengthPrefixedDecoder struct {
+	reader *bufio.Reader
+}
+
+func (d *lengthPrefixedDecoder) Decode() (Event, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(d.reader, header[:]); err != nil {
+		return Event{}, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return Event{}, fmt.Errorf("frame size %d exceeds limit %d", size, maxFrameSize)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(d.reader, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Event{}, err
+	}
+	return Event{Data: string(frame)}, nil
+}
+
+type jsonArrayDecoder struct {
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	inArray bool
+}
+
+func (d *jsonArrayDecoder) Decode() (Event, error) {
+	if d.decoder == nil {
+		// 根据第一个非空白字符判断响应是否为数组
+		first, err := d.peekNonSpace()
+		if err != nil {
+			return Event{}, err
+		}
+		d.decoder = json.NewDecoder(d.reader)
+		if first == '[' {
+			if _, err = d.decoder.Token(); err != nil {
+				return Event{}, err
+			}
+			d.inArray = true
+		}
+	}
+	if d.inArray && !d.decoder.More() {
+		// 消费数组结束符
+		if _, err := d.decoder.Token(); err != nil {
+			return Event{}, err
+		}
+		return Event{}, io.EOF
+	}
+	var item json.RawMessage
+	if err := d.decoder.Decode(&item); err != nil {
+		return Event{}, err
+	}
+	return Event{Data: string(item)}, nil
+}
+
+func (d *jsonArrayDecoder) peekNonSpace() (byte, error) {
+	for {
+		b, err := d.reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = d.reader.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
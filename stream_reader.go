@@ -0,0 +1,155 @@
+package restgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// StreamReader 拉取式的流式响应读取器，基于 CtxStreamSendEvent 实现，
+// 调用方不调用 Next 时不会继续读取后续数据，使用完毕后必须调用 Close
+type StreamReader struct {
+	items    chan streamItem
+	ack      chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+	resp     StreamResponse
+	err      error
+	pending  *Event
+	acquired bool
+	closeOne sync.Once
+}
+
+type streamItem struct {
+	resp  StreamResponse
+	event Event
+}
+
+// Stream 发起流式请求并返回 StreamReader，收到响应头后立即返回，不等待第一条事件，连接失败时直接返回错误
+func (builder *Builder) Stream(ctx context.Context, method HttpMethod, url string) (*StreamReader, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	reader := &StreamReader{
+		items:  make(chan streamItem),
+		ack:    make(chan struct{}),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	// 只需要第一次连接的响应，重连后的响应随事件一起更新
+	responses := make(chan StreamResponse, 1)
+	onResponse := func(resp StreamResponse) {
+		select {
+		case responses <- resp:
+		default:
+		}
+	}
+	go func() {
+		defer close(reader.done)
+		reader.err = builder.ctxStreamSendEvent(ctx, method, url, onResponse, func(resp StreamResponse, event Event) error {
+			select {
+			case reader.items <- streamItem{resp: resp, event: event}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			// 等待调用方再次调用 Next 后才读取下一条，实现背压
+			select {
+			case <-reader.ack:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	select {
+	case reader.resp = <-responses:
+		return reader, nil
+	case item := <-reader.items:
+		// 自定义的 StreamRestGo 没有通知响应时，以第一条事件为准
+		reader.resp = item.resp
+		reader.acquired = true
+		reader.pending = &item.event
+		return reader, nil
+	case <-reader.done:
+		select {
+		case reader.resp = <-responses:
+			return reader, nil
+		default:
+		}
+		if reader.err != nil {
+			err := reader.err
+			reader.Close()
+			return nil, err
+		}
+		return reader, nil
+	}
+}
+
+// StreamChan 发起流式请求，事件通过 channel 逐条返回，事件 channel 关闭后从错误 channel 中读取最终结果，正常结束时为 nil
+// ctx 结束时停止读取
+func (builder *Builder) StreamChan(ctx context.Context, method HttpMethod, url string) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		defer close(events)
+		errCh <- builder.CtxStreamSendEvent(ctx, method, url, func(resp StreamResponse, event Event) error {
+			select {
+			case events <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return events, errCh
+}
+
+// Next 读取下一条事件，流正常结束时返回 io.EOF
+func (r *StreamReader) Next() (Event, error) {
+	if r.pending != nil {
+		event := *r.pending
+		r.pending = nil
+		return event, nil
+	}
+	return r.next()
+}
+
+func (r *StreamReader) next() (Event, error) {
+	if r.acquired {
+		// 通知读取协程继续读取下一条
+		select {
+		case r.ack <- struct{}{}:
+		case <-r.done:
+		}
+		r.acquired = false
+	}
+	select {
+	case item := <-r.items:
+		r.resp = item.resp
+		r.acquired = true
+		return item.event, nil
+	case <-r.done:
+		if r.err != nil {
+			return Event{}, r.err
+		}
+		return Event{}, io.EOF
+	}
+}
+
+// Response 获取流式响应的状态码、响应头等信息，没有收到响应时返回 nil
+func (r *StreamReader) Response() StreamResponse {
+	return r.resp
+}
+
+// Close 断开连接并释放资源
+func (r *StreamReader) Close() error {
+	r.closeOne.Do(func() {
+		r.cancel()
+		<-r.done
+	})
+	if r.err != nil && !errors.Is(r.err, context.Canceled) {
+		return r.err
+	}
+	return nil
+}
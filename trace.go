@@ -0,0 +1,149 @@
+package restgo
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TraceInfo 单次请求各阶段的耗时，通过 Builder.EnableTrace 开启，复用连接时 DNS、建连、TLS 握手耗时为0
+type TraceInfo struct {
+	// DNSLookup DNS 解析耗时
+	DNSLookup time.Duration
+	// TCPConnect 建立 TCP 连接耗时
+	TCPConnect time.Duration
+	// TLSHandshake TLS 握手耗时
+	TLSHandshake time.Duration
+	// ConnectTime 获取连接的总耗时，包括 DNS 解析、建连以及 TLS 握手
+	ConnectTime time.Duration
+	// ServerTime 请求发送完毕到收到响应第一个字节的耗时
+	ServerTime time.Duration
+	// TimeToFirstByte 开始请求到收到响应第一个字节的耗时
+	TimeToFirstByte time.Duration
+	// ContentTransfer 收到响应第一个字节到读取完响应体的耗时，RawResponse 模式下为0
+	ContentTransfer time.Duration
+	// Total 请求总耗时
+	Total time.Duration
+	// ConnReused 是否复用了连接
+	ConnReused bool
+	// ConnWasIdle 复用的连接是否来自空闲连接池
+	ConnWasIdle bool
+	// ConnIdleTime 复用的连接在空闲连接池中的时长
+	ConnIdleTime time.Duration
+	// RemoteAddr 连接的远端地址
+	RemoteAddr string
+}
+
+// EnableTrace 开启请求耗时统计，每次请求使用独立的 httptrace，结果通过 Response.TraceInfo 获取
+func (builder *Builder) EnableTrace() *Builder {
+	builder.trace = true
+	return builder
+}
+
+// clientTracer 记录单次请求各阶段的时间点，建连相关的回调可能在其他协程中执行
+type clientTracer struct {
+	lock         sync.Mutex
+	start        time.Time
+	getConn      time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	connInfo     httptrace.GotConnInfo
+}
+
+func newClientTracer() *clientTracer {
+	return &clientTracer{start: time.Now()}
+}
+
+// withTrace 将 tracer 的回调添加到 ctx 中，已有的 httptrace 回调仍然会执行
+func (t *clientTracer) withTrace(ctx context.Context) context.Context {
+	mark := func(at *time.Time) {
+		t.lock.Lock()
+		*at = time.Now()
+		t.lock.Unlock()
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			mark(&t.getConn)
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mark(&t.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			// 多个地址并行建连时以第一次开始为准
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				mark(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mark(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.gotConn = time.Now()
+			t.connInfo = info
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mark(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			mark(&t.firstByte)
+		},
+	})
+}
+
+// traceInfo 根据记录的时间点计算各阶段耗时，end 为读取完响应体的时间，为零值时不统计传输耗时
+func (t *clientTracer) traceInfo(end time.Time) TraceInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	info := TraceInfo{
+		DNSLookup:       since(t.dnsStart, t.dnsDone),
+		TCPConnect:      since(t.connectStart, t.connectDone),
+		TLSHandshake:    since(t.tlsStart, t.tlsDone),
+		ConnectTime:     since(t.getConn, t.gotConn),
+		ServerTime:      since(t.wroteRequest, t.firstByte),
+		TimeToFirstByte: since(t.start, t.firstByte),
+		ContentTransfer: since(t.firstByte, end),
+		ConnReused:      t.connInfo.Reused,
+		ConnWasIdle:     t.connInfo.WasIdle,
+		ConnIdleTime:    t.connInfo.IdleTime,
+	}
+	if t.connInfo.Conn != nil {
+		info.RemoteAddr = t.connInfo.Conn.RemoteAddr().String()
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	info.Total = end.Sub(t.start)
+	return info
+}
+
+// since 两个时间点之间的耗时，任意一个时间点没有记录时为0
+func since(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
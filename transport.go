@@ -0,0 +1,149 @@
+package restgo
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportOption http.Transport 配置项
+type TransportOption func(cfg *transportConfig)
+
+type transportConfig struct {
+	dialTimeout           time.Duration
+	keepAlive             time.Duration
+	maxIdleConns          int
+	maxIdleConnsPerHost   int
+	maxConnsPerHost       int
+	idleConnTimeout       time.Duration
+	responseHeaderTimeout time.Duration
+	tlsHandshakeTimeout   time.Duration
+	expectContinueTimeout time.Duration
+	tlsClientConfig       *tls.Config
+	proxy                 func(*http.Request) (*url.URL, error)
+}
+
+// 配置参考：https://xujiahua.github.io/posts/20200723-golang-http-reuse/
+func defaultTransportConfig() *transportConfig {
+	return &transportConfig{
+		dialTimeout:           30 * time.Second,
+		keepAlive:             30 * time.Second,
+		maxIdleConnsPerHost:   512,
+		maxConnsPerHost:       512,
+		idleConnTimeout:       90 * time.Second,
+		tlsHandshakeTimeout:   10 * time.Second,
+		expectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// DialTimeout 建立TCP连接的超时时间
+func DialTimeout(d time.Duration) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.dialTimeout = d
+	}
+}
+
+// KeepAlive TCP keepalive 探测间隔
+func KeepAlive(d time.Duration) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.keepAlive = d
+	}
+}
+
+// MaxIdleConns 所有host总的最大空闲连接数，0表示不限制
+func MaxIdleConns(n int) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.maxIdleConns = n
+	}
+}
+
+// MaxIdleConnsPerHost 每个host的最大空闲连接数
+func MaxIdleConnsPerHost(n int) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.maxIdleConnsPerHost = n
+	}
+}
+
+// MaxConnsPerHost 每个host的最大连接数，0表示不限制
+func MaxConnsPerHost(n int) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.maxConnsPerHost = n
+	}
+}
+
+// IdleConnTimeout 空闲连接的保留时间
+func IdleConnTimeout(d time.Duration) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.idleConnTimeout = d
+	}
+}
+
+// ResponseHeaderTimeout 请求写完后等待响应头的超时时间，不包含读取响应体的时间
+func ResponseHeaderTimeout(d time.Duration) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.responseHeaderTimeout = d
+	}
+}
+
+// TLSHandshakeTimeout TLS握手超时时间
+func TLSHandshakeTimeout(d time.Duration) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.tlsHandshakeTimeout = d
+	}
+}
+
+// TLSClientConfig 自定义TLS配置
+func TLSClientConfig(tlsConfig *tls.Config) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.tlsClientConfig = tlsConfig
+	}
+}
+
+// Proxy 自定义代理，默认不使用代理，可传入 http.ProxyFromEnvironment 读取环境变量
+func Proxy(proxy func(*http.Request) (*url.URL, error)) TransportOption {
+	return func(cfg *transportConfig) {
+		cfg.proxy = proxy
+	}
+}
+
+// ProxyURL 使用固定地址的代理
+func ProxyURL(proxyURL *url.URL) TransportOption {
+	return Proxy(http.ProxyURL(proxyURL))
+}
+
+// NewTransport 基于默认配置创建 http.Transport，通过 opts 覆盖需要调整的配置
+func NewTransport(opts ...TransportOption) *http.Transport {
+	cfg := defaultTransportConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	dialer := &net.Dialer{
+		Timeout:   cfg.dialTimeout,
+		KeepAlive: cfg.keepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 cfg.proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.maxIdleConns,
+		MaxIdleConnsPerHost:   cfg.maxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.maxConnsPerHost,
+		IdleConnTimeout:       cfg.idleConnTimeout,
+		ResponseHeaderTimeout: cfg.responseHeaderTimeout,
+		TLSHandshakeTimeout:   cfg.tlsHandshakeTimeout,
+		ExpectContinueTimeout: cfg.expectContinueTimeout,
+		TLSClientConfig:       cfg.tlsClientConfig,
+	}
+}
+
+// ConfigureDefaultTransport 调整全局默认客户端使用的 http.Transport，
+// 非并发安全，应当在程序初始化阶段、发出请求之前调用
+func ConfigureDefaultTransport(opts ...TransportOption) {
+	cli := initClient()
+	if old, ok := cli.Transport.(*http.Transport); ok {
+		old.CloseIdleConnections()
+	}
+	cli.Transport = NewTransport(opts...)
+}
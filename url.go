@@ -0,0 +1,59 @@
+package restgo
+
+import (
+	"net/url"
+	"strings"
+)
+
+// joinURL 基于 RFC 3986 将 ref 解析为相对于 baseURL 的地址：
+//   - ref 为带有 host 的绝对地址时直接使用 ref，user:1 这类没有 host 的地址按相对路径处理
+//   - baseURL 的路径会被保留，/api/v2 与 users、/users 拼接结果均为 /api/v2/users
+//   - baseURL 与 ref 中的查询参数会合并，同名参数以 ref 为准
+func joinURL(baseURL, ref string) (string, error) {
+	if baseURL == "" {
+		return ref, nil
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if refURL.Host != "" {
+		return ref, nil
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	if ref == "" {
+		return base.String(), nil
+	}
+	baseQuery := base.Query()
+	base.RawQuery = ""
+	base.Fragment = ""
+
+	// ref 只有查询参数或锚点时保留 baseURL 的路径，不追加斜杠
+	relative := ref
+	if !strings.HasPrefix(relative, "?") && !strings.HasPrefix(relative, "#") {
+		if !strings.HasSuffix(base.Path, "/") {
+			base.Path += "/"
+			if base.RawPath != "" {
+				base.RawPath += "/"
+			}
+		}
+		// 去掉开头的斜杠并以 ./ 开头，避免覆盖 baseURL 的路径，同时避免 a:b 形式的路径被识别为 scheme
+		relative = "./" + strings.TrimLeft(relative, "/")
+	}
+	relURL, err := url.Parse(relative)
+	if err != nil {
+		return "", err
+	}
+	resolved := base.ResolveReference(relURL)
+
+	query := baseQuery
+	for k, v := range relURL.Query() {
+		query[k] = v
+	}
+	resolved.RawQuery = query.Encode()
+	return resolved.String(), nil
+}
@@ -1,39 +1,23 @@
 package restgo
 
 import (
-	"fmt"
-	"io/ioutil"
-	"os"
+	"net/http"
 	"strings"
 )
 
-// WriteFile 如果响应是一个文件，可以通过该方法下载文件，path为文件下载路径
-func writeFile(bodyRaw []byte,path string) error {
-	if path == "" {
-		return fmt.Errorf("writefile failed, path must not be emoty")
+// mergeSMap 合并两个请求头，请求头名称不区分大小写，override 中的值优先
+func mergeSMap(base, override map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[http.CanonicalHeaderKey(k)] = v
 	}
-	dir := path[:strings.LastIndex(path, "/")]
-	exists, err := pathExists(dir)
-	if err != nil {
-		return err
+	for k, v := range override {
+		merged[http.CanonicalHeaderKey(k)] = v
 	}
-	if !exists {
-		err := os.MkdirAll(dir, os.ModePerm)
-		if err != nil {
-			return err
-		}
-	}
-	return ioutil.WriteFile(path, bodyRaw, os.ModePerm) // ignore_security_alert
+	return merged
 }
 
-
-func pathExists(path string) (bool, error) {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true, nil
-	}
-	if os.IsNotExist(err) {
-		return false, nil
-	}
-	return false, err
+// shellQuoteEscape 转义单引号，使内容可以放在 shell 的单引号字符串中
+func shellQuoteEscape(s string) string {
+	return strings.ReplaceAll(s, "'", `'\''`)
 }